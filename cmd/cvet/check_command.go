@@ -72,6 +72,11 @@ func checkCommandLogger(pass *analysis.Pass, node *ast.FuncDecl) {
 	}
 
 	call, ok := firstStmt.Rhs[0].(*ast.CallExpr)
+	// The kcore bus injects a logger whose command arg is the function name
+	if ok && isSelector(call.Fun, "kcore", "Logger") {
+		checkStmtHaveNoRawSlog(pass, node.Body.List[1:])
+		return
+	}
 	if !ok || !isSelector(call.Fun, "slog", "With") {
 		pass.Reportf(node.Pos(), "logger creation must use slog.With")
 		return
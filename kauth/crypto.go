@@ -26,15 +26,31 @@ func GenerateCookieSecret() []byte {
 	return secret
 }
 
-func LoadCookieSecret(cookieSecretString string) []byte {
+// ParseCookieSecret decodes a hex encoded 32-byte secret, such as one loaded by kcore.ConfigLoader
+func ParseCookieSecret(cookieSecretString string) ([]byte, error) {
 	cookiesSecret, err := hex.DecodeString(cookieSecretString)
 	if err != nil {
-		err = kcore.Wrap(err, "error decoding cookie secret")
-		slog.Error(err.Error())
-		os.Exit(1)
+		return nil, kcore.Wrap(err, "error decoding cookie secret")
+	}
+	if err := CheckCookieSecret(cookiesSecret); err != nil {
+		return nil, err
+	}
+	return cookiesSecret, nil
+}
+
+// CheckCookieSecret validates a cookie secret, such as a []byte field of a kcore.ConfigLoader config
+func CheckCookieSecret(cookieSecret []byte) error {
+	if len(cookieSecret) != 32 {
+		return ErrCookieBadLength
 	}
-	if len(cookiesSecret) != 32 {
-		slog.Error(ErrCookieBadLength.Error())
+	return nil
+}
+
+// LoadCookieSecret parses the secret or exits, prefer ParseCookieSecret or CheckCookieSecret
+func LoadCookieSecret(cookieSecretString string) []byte {
+	cookiesSecret, err := ParseCookieSecret(cookieSecretString)
+	if err != nil {
+		slog.Error(err.Error())
 		os.Exit(1)
 	}
 	slog.Info("cookie secret loaded")
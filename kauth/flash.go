@@ -0,0 +1,243 @@
+package kauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/martinlehoux/kagamigo/kcore"
+	"golang.org/x/exp/slog"
+)
+
+const flashCookieName = "flash"
+
+type FlashLevel string
+
+const (
+	FlashSuccess FlashLevel = "success"
+	FlashInfo    FlashLevel = "info"
+	FlashWarning FlashLevel = "warning"
+	FlashError   FlashLevel = "error"
+)
+
+// A Flash is a message shown once on the next page, such as after a POST-redirect-GET.
+// Message is a ki18n key: @ki18n.Tr(ctx, flash.Message, flash.Args...)
+type Flash struct {
+	Level   FlashLevel `json:"level"`
+	Message string     `json:"message"`
+	Args    []any      `json:"args,omitempty"`
+}
+
+// A FlashStore keeps the flashes of a response until the next request
+type FlashStore interface {
+	Save(w http.ResponseWriter, r *http.Request, flashes []Flash) error
+	// Load returns the pending flashes and clears them
+	Load(w http.ResponseWriter, r *http.Request) ([]Flash, error)
+}
+
+// CookieFlashStore keeps flashes in a short-lived cookie, encrypted with the cookie secret.
+// The expiry is sealed with the flashes, so that a captured cookie can't be replayed after MaxAge.
+type CookieFlashStore struct {
+	Secret []byte
+	Domain string
+	MaxAge time.Duration
+	Now    func() time.Time // injectable time provider
+}
+
+// flashCookie is the sealed content of the flash cookie
+type flashCookie struct {
+	ExpiresAt int64           `json:"expires_at"`
+	Flashes   json.RawMessage `json:"flashes"`
+}
+
+func NewCookieFlashStore(secret []byte, domain string) CookieFlashStore {
+	return CookieFlashStore{Secret: secret, Domain: domain, MaxAge: 5 * time.Minute}
+}
+
+// setCookie replaces the flash cookie already set on the response, so that it is sent once
+func (s CookieFlashStore) setCookie(w http.ResponseWriter, cookie *http.Cookie) {
+	headers := w.Header().Values("Set-Cookie")
+	w.Header().Del("Set-Cookie")
+	for _, header := range headers {
+		if !strings.HasPrefix(header, flashCookieName+"=") {
+			w.Header().Add("Set-Cookie", header)
+		}
+	}
+	cookie.Name, cookie.Domain, cookie.Path = flashCookieName, s.Domain, "/"
+	cookie.HttpOnly, cookie.Secure, cookie.SameSite = true, true, http.SameSiteLaxMode
+	http.SetCookie(w, cookie)
+}
+
+func (s CookieFlashStore) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+func (s CookieFlashStore) Save(w http.ResponseWriter, r *http.Request, flashes []Flash) error {
+	content, err := json.Marshal(flashes)
+	if err != nil {
+		return kcore.Wrap(err, "error marshalling flashes")
+	}
+	content, err = json.Marshal(flashCookie{ExpiresAt: s.now().Add(s.MaxAge).Unix(), Flashes: content})
+	if err != nil {
+		return kcore.Wrap(err, "error marshalling flash cookie")
+	}
+	s.setCookie(w, &http.Cookie{Value: encrypt(s.Secret, string(content)), MaxAge: int(s.MaxAge.Seconds())})
+	return nil
+}
+
+func (s CookieFlashStore) Load(w http.ResponseWriter, r *http.Request) ([]Flash, error) {
+	cookie, err := r.Cookie(flashCookieName)
+	if errors.Is(err, http.ErrNoCookie) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, kcore.Wrap(err, "error reading flash cookie")
+	}
+	s.setCookie(w, &http.Cookie{Value: "", Expires: time.Unix(0, 0), MaxAge: -1})
+	content, err := decrypt(s.Secret, cookie.Value)
+	if err != nil {
+		return nil, kcore.Wrap(err, "error decrypting flash cookie")
+	}
+	var sealed flashCookie
+	if err := json.Unmarshal([]byte(content), &sealed); err != nil {
+		return nil, kcore.Wrap(err, "error unmarshalling flash cookie")
+	}
+	if s.now().After(time.Unix(sealed.ExpiresAt, 0)) {
+		return nil, fmt.Errorf("%w: flash", ErrCookieExpired)
+	}
+	return decodeFlashes(sealed.Flashes)
+}
+
+// decodeFlashes keeps integer args as int64, so that they can be formatted with %d
+func decodeFlashes(content []byte) ([]Flash, error) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	var flashes []Flash
+	if err := decoder.Decode(&flashes); err != nil {
+		return nil, kcore.Wrap(err, "error unmarshalling flashes")
+	}
+	for _, flash := range flashes {
+		for i, arg := range flash.Args {
+			if number, ok := arg.(json.Number); ok {
+				if n, err := number.Int64(); err == nil {
+					flash.Args[i] = n
+				} else if f, err := number.Float64(); err == nil {
+					flash.Args[i] = f
+				}
+			}
+		}
+	}
+	return flashes, nil
+}
+
+// SessionFlashStore keeps flashes in memory, by session, for single process apps.
+// Flashes not read within MaxAge are dropped, so that sessions which never come back don't pile up.
+type SessionFlashStore struct {
+	// Session identifies the session of the request, such as the logged in user ID
+	Session func(r *http.Request) (string, bool)
+	MaxAge  time.Duration
+	Now     func() time.Time // injectable time provider
+
+	mutex   sync.Mutex
+	flashes map[string]sessionFlashes
+}
+
+type sessionFlashes struct {
+	flashes   []Flash
+	expiresAt time.Time
+}
+
+func NewSessionFlashStore(session func(r *http.Request) (string, bool)) *SessionFlashStore {
+	return &SessionFlashStore{Session: session, MaxAge: 5 * time.Minute, flashes: map[string]sessionFlashes{}}
+}
+
+func (s *SessionFlashStore) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+func (s *SessionFlashStore) Save(w http.ResponseWriter, r *http.Request, flashes []Flash) error {
+	session, ok := s.Session(r)
+	if !ok {
+		return ErrUserNotLoggedIn
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := s.now()
+	for other, pending := range s.flashes {
+		if now.After(pending.expiresAt) {
+			delete(s.flashes, other)
+		}
+	}
+	s.flashes[session] = sessionFlashes{flashes: flashes, expiresAt: now.Add(s.MaxAge)}
+	return nil
+}
+
+func (s *SessionFlashStore) Load(w http.ResponseWriter, r *http.Request) ([]Flash, error) {
+	session, ok := s.Session(r)
+	if !ok {
+		return nil, nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	pending, ok := s.flashes[session]
+	delete(s.flashes, session)
+	if !ok || s.now().After(pending.expiresAt) {
+		return nil, nil
+	}
+	return pending.flashes, nil
+}
+
+type flashContext struct{}
+
+type flashState struct {
+	store    FlashStore
+	incoming []Flash
+	mutex    sync.Mutex
+	outgoing []Flash
+}
+
+// FlashMiddleware reads the pending flashes once and exposes them with Flashes
+func FlashMiddleware(store FlashStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			incoming, err := store.Load(w, r)
+			if err != nil {
+				// A stale or tampered flash is not worth failing the request
+				slog.Warn(kcore.Wrap(err, "error loading flashes").Error())
+			}
+			ctx := context.WithValue(r.Context(), flashContext{}, &flashState{store: store, incoming: incoming})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Flashes returns the flashes added by the previous request, for templ components
+func Flashes(ctx context.Context) []Flash {
+	state, ok := ctx.Value(flashContext{}).(*flashState)
+	if !ok {
+		return nil
+	}
+	return state.incoming
+}
+
+// AddFlash adds a flash for the next request, before the response is written (e.g. before redirecting)
+func AddFlash(w http.ResponseWriter, r *http.Request, level FlashLevel, message string, args ...any) error {
+	state, ok := r.Context().Value(flashContext{}).(*flashState)
+	kcore.Assert(ok, "AddFlash requires FlashMiddleware")
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	state.outgoing = append(state.outgoing, Flash{Level: level, Message: message, Args: args})
+	return state.store.Save(w, r, state.outgoing)
+}
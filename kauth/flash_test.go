@@ -0,0 +1,128 @@
+package kauth
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveFlashes runs a request through FlashMiddleware with the cookies of the previous response
+func serveFlashes(store FlashStore, previous *httptest.ResponseRecorder, handler http.HandlerFunc) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if previous != nil {
+		for _, cookie := range previous.Result().Cookies() {
+			r.AddCookie(cookie)
+		}
+	}
+	w := httptest.NewRecorder()
+	FlashMiddleware(store)(handler).ServeHTTP(w, r)
+	return w
+}
+
+func TestCookieFlashStore_readOnce(t *testing.T) {
+	store := NewCookieFlashStore(GenerateCookieSecret(), "localhost")
+
+	posted := serveFlashes(store, nil, func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, AddFlash(w, r, FlashSuccess, "Saved %s", "item"))
+		require.NoError(t, AddFlash(w, r, FlashWarning, "%d items left", 3))
+		http.Redirect(w, r, "/items", http.StatusSeeOther)
+	})
+	require.Len(t, posted.Result().Cookies(), 1, "the flash cookie is set once")
+	cookie := posted.Result().Cookies()[0]
+	assert.True(t, cookie.HttpOnly)
+	assert.True(t, cookie.Secure)
+	assert.Equal(t, 300, cookie.MaxAge)
+
+	var flashes []Flash
+	redirected := serveFlashes(store, posted, func(w http.ResponseWriter, r *http.Request) {
+		flashes = Flashes(r.Context())
+	})
+	assert.Equal(t, []Flash{
+		{Level: FlashSuccess, Message: "Saved %s", Args: []any{"item"}},
+		{Level: FlashWarning, Message: "%d items left", Args: []any{int64(3)}},
+	}, flashes)
+	assert.Equal(t, "3 items left", fmt.Sprintf(flashes[1].Message, flashes[1].Args...))
+
+	serveFlashes(store, redirected, func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, Flashes(r.Context()), "flashes are cleared after being read")
+	})
+}
+
+func TestCookieFlashStore_tamperedCookie(t *testing.T) {
+	store := NewCookieFlashStore(GenerateCookieSecret(), "localhost")
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: "flash", Value: "tampered"})
+	w := httptest.NewRecorder()
+
+	FlashMiddleware(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, Flashes(r.Context()))
+	})).ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, -1, w.Result().Cookies()[0].MaxAge)
+}
+
+func TestCookieFlashStore_replayedCookie(t *testing.T) {
+	now := time.Date(2025, 12, 23, 12, 0, 0, 0, time.UTC)
+	store := NewCookieFlashStore(GenerateCookieSecret(), "localhost")
+	store.Now = func() time.Time { return now }
+	posted := serveFlashes(store, nil, func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, AddFlash(w, r, FlashSuccess, "Saved"))
+	})
+
+	now = now.Add(6 * time.Minute) // the browser dropped the cookie, but it was captured
+	serveFlashes(store, posted, func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, Flashes(r.Context()))
+	})
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(posted.Result().Cookies()[0])
+	_, err := store.Load(httptest.NewRecorder(), r)
+	assert.ErrorIs(t, err, ErrCookieExpired)
+}
+
+func TestSessionFlashStore(t *testing.T) {
+	session := "user-1"
+	store := NewSessionFlashStore(func(r *http.Request) (string, bool) { return session, session != "" })
+
+	serveFlashes(store, nil, func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, AddFlash(w, r, FlashError, "Payment failed"))
+	})
+	serveFlashes(store, nil, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, []Flash{{Level: FlashError, Message: "Payment failed"}}, Flashes(r.Context()))
+	})
+	serveFlashes(store, nil, func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, Flashes(r.Context()))
+	})
+
+	session = ""
+	serveFlashes(store, nil, func(w http.ResponseWriter, r *http.Request) {
+		assert.ErrorIs(t, AddFlash(w, r, FlashInfo, "Welcome"), ErrUserNotLoggedIn)
+	})
+}
+
+func TestSessionFlashStore_evictsUnread(t *testing.T) {
+	now := time.Date(2025, 12, 23, 12, 0, 0, 0, time.UTC)
+	session := "user-1"
+	store := NewSessionFlashStore(func(r *http.Request) (string, bool) { return session, true })
+	store.Now = func() time.Time { return now }
+	serveFlashes(store, nil, func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, AddFlash(w, r, FlashInfo, "Welcome"))
+	})
+
+	now = now.Add(6 * time.Minute)
+	session = "user-2"
+	serveFlashes(store, nil, func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, AddFlash(w, r, FlashInfo, "Welcome"))
+	})
+
+	assert.Len(t, store.flashes, 1, "the unread flashes of user-1 are dropped")
+	session = "user-1"
+	serveFlashes(store, nil, func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, Flashes(r.Context()))
+	})
+}
@@ -0,0 +1,261 @@
+package kcore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/exp/slog"
+)
+
+var (
+	ErrNoBusHandler  = errors.New("no handler registered")
+	ErrBusResultType = errors.New("query result type mismatch")
+)
+
+// BusCall describes the command or query going through the bus middlewares
+type BusCall struct {
+	// Name is the name of the handler following the cvet convention, from the input type: CreateItemCommand for CreateItem
+	Name  string
+	Kind  string // "command" or "query"
+	Input any
+}
+
+// A BusMiddleware wraps the calls of commands and queries. It returns the int code and error of next, or its own.
+type BusMiddleware func(ctx context.Context, call BusCall, next func(ctx context.Context) (int, error)) (int, error)
+
+type busHandler struct {
+	name   string
+	handle func(ctx context.Context, input any) (any, int, error)
+}
+
+// Bus dispatches commands and queries to their handler, registered by input type
+type Bus struct {
+	middlewares []BusMiddleware
+	handlers    map[reflect.Type]busHandler
+}
+
+// NewBus creates a bus, middlewares run in order around every call
+func NewBus(middlewares ...BusMiddleware) *Bus {
+	return &Bus{middlewares: middlewares, handlers: map[reflect.Type]busHandler{}}
+}
+
+// handlerName names the handler of an input type with the cvet suffix of its kind, as function names are not
+// available for method values and closures
+func handlerName(input reflect.Type, kind string) string {
+	name := input.Name()
+	if name == "" {
+		name = input.String()
+	}
+	first, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToUpper(first)) + name[size:] + kind
+}
+
+// RegisterCommand registers the handler of commands of type C, following the cvet convention (int, error)
+func RegisterCommand[C any](bus *Bus, handler func(ctx context.Context, cmd C) (int, error)) {
+	bus.handlers[reflect.TypeFor[C]()] = busHandler{
+		name: handlerName(reflect.TypeFor[C](), "Command"),
+		handle: func(ctx context.Context, input any) (any, int, error) {
+			code, err := handler(ctx, input.(C)) //nolint:forcetypeassert
+			return nil, code, err
+		},
+	}
+}
+
+// RegisterQuery registers the handler of queries of type Q, following the cvet convention (T, int, error)
+func RegisterQuery[Q any, T any](bus *Bus, handler func(ctx context.Context, query Q) (T, int, error)) {
+	bus.handlers[reflect.TypeFor[Q]()] = busHandler{
+		name: handlerName(reflect.TypeFor[Q](), "Query"),
+		handle: func(ctx context.Context, input any) (any, int, error) {
+			return handler(ctx, input.(Q)) //nolint:forcetypeassert
+		},
+	}
+}
+
+func (b *Bus) call(ctx context.Context, kind string, input any) (any, int, error) {
+	handler, ok := b.handlers[reflect.TypeOf(input)]
+	if !ok {
+		return nil, http.StatusInternalServerError, fmt.Errorf("%w: %T", ErrNoBusHandler, input)
+	}
+	call := BusCall{Name: handler.name, Kind: kind, Input: input}
+	logger := slog.With(slog.String("command", handler.name))
+
+	var result any
+	next := func(ctx context.Context) (int, error) {
+		var code int
+		var err error
+		result, code, err = handler.handle(ctx, input)
+		return code, err
+	}
+	for i := len(b.middlewares) - 1; i >= 0; i-- {
+		middleware, inner := b.middlewares[i], next
+		next = func(ctx context.Context) (int, error) { return middleware(ctx, call, inner) }
+	}
+	code, err := next(WithLogger(ctx, logger))
+	return result, code, err
+}
+
+// Dispatch runs the command handler of C
+func Dispatch[C any](ctx context.Context, bus *Bus, cmd C) (int, error) {
+	_, code, err := bus.call(ctx, "command", cmd)
+	return code, err
+}
+
+// Ask runs the query handler of Q
+func Ask[T any, Q any](ctx context.Context, bus *Bus, query Q) (T, int, error) {
+	result, code, err := bus.call(ctx, "query", query)
+	value, ok := result.(T)
+	// The result is nil when a middleware rejected the call before the handler
+	if !ok && result != nil {
+		var zero T
+		return zero, http.StatusInternalServerError, fmt.Errorf("%w: %T returns %T, not %s", ErrBusResultType, query, result, reflect.TypeFor[T]())
+	}
+	return value, code, err
+}
+
+type loggerContext struct{}
+
+// WithLogger sets the logger returned by Logger
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerContext{}, logger)
+}
+
+// Logger returns the logger injected by the bus, with the command attribute set to the handler name, or the default logger
+func Logger(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerContext{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// TimingMiddleware logs the duration and code of every call
+func TimingMiddleware(ctx context.Context, call BusCall, next func(ctx context.Context) (int, error)) (int, error) {
+	start := time.Now()
+	code, err := next(ctx)
+	Logger(ctx).Info("handled "+call.Kind, slog.Int("code", code), slog.Duration("duration", time.Since(start)))
+	return code, err
+}
+
+// AuthMiddleware rejects calls when check fails, with a 403 code
+func AuthMiddleware(check func(ctx context.Context, call BusCall) error) BusMiddleware {
+	return func(ctx context.Context, call BusCall, next func(ctx context.Context) (int, error)) (int, error) {
+		if err := check(ctx, call); err != nil {
+			return http.StatusForbidden, err
+		}
+		return next(ctx)
+	}
+}
+
+// TracingMiddleware wraps calls in a span, started by start and ended by the returned function
+func TracingMiddleware(start func(ctx context.Context, name string) (context.Context, func(err error))) BusMiddleware {
+	return func(ctx context.Context, call BusCall, next func(ctx context.Context) (int, error)) (int, error) {
+		ctx, end := start(ctx, call.Name)
+		code, err := next(ctx)
+		end(err)
+		return code, err
+	}
+}
+
+type txContext struct{}
+
+// Tx returns the transaction opened by TransactionMiddleware
+func Tx(ctx context.Context) (*sql.Tx, bool) {
+	tx, ok := ctx.Value(txContext{}).(*sql.Tx)
+	return tx, ok
+}
+
+// TransactionMiddleware runs commands in a transaction, committed unless the command returns an error or an error code
+func TransactionMiddleware(db *sql.DB) BusMiddleware {
+	return func(ctx context.Context, call BusCall, next func(ctx context.Context) (int, error)) (int, error) {
+		if call.Kind != "command" {
+			return next(ctx)
+		}
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return http.StatusInternalServerError, Wrap(err, "error beginning transaction")
+		}
+		// Rolls back when the command panics, a no-op returning sql.ErrTxDone after commit
+		defer tx.Rollback() //nolint:errcheck
+		code, err := next(context.WithValue(ctx, txContext{}, tx))
+		if err != nil || code >= http.StatusBadRequest {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				return code, errors.Join(err, Wrap(rollbackErr, "error rolling back transaction"))
+			}
+			return code, err
+		}
+		if err := tx.Commit(); err != nil {
+			return http.StatusInternalServerError, Wrap(err, "error committing transaction")
+		}
+		return code, nil
+	}
+}
+
+// HTTPStatus maps the int code of a command or query to an HTTP status, a server error for errors with a success code
+func HTTPStatus(code int, err error) int {
+	switch {
+	case err != nil && code < http.StatusBadRequest:
+		return http.StatusInternalServerError
+	case code >= 100 && code <= 599:
+		return code
+	case err != nil:
+		return http.StatusInternalServerError
+	default:
+		return http.StatusOK
+	}
+}
+
+// WriteError writes the error response of a failed call. Server errors are logged, not exposed.
+func WriteError(ctx context.Context, w http.ResponseWriter, code int, err error) {
+	status := HTTPStatus(code, err)
+	message := http.StatusText(status)
+	if status >= http.StatusInternalServerError {
+		if err != nil {
+			Logger(ctx).Error(err.Error())
+		}
+	} else if err != nil {
+		message = err.Error()
+	}
+	http.Error(w, message, status)
+}
+
+// CommandHTTP adapts a command to an http handler: decode builds the command from the request,
+// and success writes the response when the command succeeds
+func CommandHTTP[C any](bus *Bus, decode func(r *http.Request) (C, error), success func(w http.ResponseWriter, r *http.Request)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cmd, err := decode(r)
+		if err != nil {
+			WriteError(r.Context(), w, http.StatusBadRequest, err)
+			return
+		}
+		code, err := Dispatch(r.Context(), bus, cmd)
+		if err != nil || HTTPStatus(code, err) >= http.StatusBadRequest {
+			WriteError(r.Context(), w, code, err)
+			return
+		}
+		success(w, r)
+	})
+}
+
+// QueryHTTP adapts a query to an http handler: decode builds the query from the request,
+// and render writes the result when the query succeeds
+func QueryHTTP[T any, Q any](bus *Bus, decode func(r *http.Request) (Q, error), render func(w http.ResponseWriter, r *http.Request, result T)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query, err := decode(r)
+		if err != nil {
+			WriteError(r.Context(), w, http.StatusBadRequest, err)
+			return
+		}
+		result, code, err := Ask[T](r.Context(), bus, query)
+		if err != nil || HTTPStatus(code, err) >= http.StatusBadRequest {
+			WriteError(r.Context(), w, code, err)
+			return
+		}
+		render(w, r, result)
+	})
+}
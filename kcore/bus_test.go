@@ -0,0 +1,220 @@
+package kcore
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+)
+
+type createItem struct {
+	Name string
+}
+
+type getItem struct {
+	Name string
+}
+
+func CreateItemCommand(ctx context.Context, cmd createItem) (int, error) {
+	logger := Logger(ctx)
+	if cmd.Name == "" {
+		return http.StatusBadRequest, errors.New("name is required")
+	}
+	logger.Info("item created")
+	return http.StatusCreated, nil
+}
+
+func GetItemQuery(ctx context.Context, query getItem) (string, int, error) {
+	if query.Name == "missing" {
+		return "", http.StatusNotFound, errors.New("item not found")
+	}
+	return "item " + query.Name, http.StatusOK, nil
+}
+
+func TestBus_dispatchAndAsk(t *testing.T) {
+	ctx := context.Background()
+	bus := NewBus()
+	RegisterCommand(bus, CreateItemCommand)
+	RegisterQuery(bus, GetItemQuery)
+
+	code, err := Dispatch(ctx, bus, createItem{Name: "book"})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, code)
+
+	result, code, err := Ask[string](ctx, bus, getItem{Name: "book"})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "item book", result)
+
+	_, err = Dispatch(ctx, bus, "unregistered")
+	assert.ErrorIs(t, err, ErrNoBusHandler)
+}
+
+func TestBus_middlewares(t *testing.T) {
+	ctx := context.Background()
+	calls := []string{}
+	trace := func(name string) BusMiddleware {
+		return func(ctx context.Context, call BusCall, next func(ctx context.Context) (int, error)) (int, error) {
+			calls = append(calls, name+" "+call.Kind+" "+call.Name)
+			return next(ctx)
+		}
+	}
+	bus := NewBus(trace("first"), trace("second"), AuthMiddleware(func(ctx context.Context, call BusCall) error {
+		if call.Kind == "query" {
+			return errors.New("not allowed")
+		}
+		return nil
+	}))
+	RegisterCommand(bus, CreateItemCommand)
+	RegisterQuery(bus, GetItemQuery)
+
+	_, err := Dispatch(ctx, bus, createItem{Name: "book"})
+	require.NoError(t, err)
+	_, code, err := Ask[string](ctx, bus, getItem{Name: "book"})
+
+	assert.EqualError(t, err, "not allowed")
+	assert.Equal(t, http.StatusForbidden, code)
+	assert.Equal(t, []string{
+		"first command CreateItemCommand", "second command CreateItemCommand",
+		"first query GetItemQuery", "second query GetItemQuery",
+	}, calls)
+}
+
+func TestBus_injectsLogger(t *testing.T) {
+	var output strings.Builder
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&output, nil)))
+	defer slog.SetDefault(defaultLogger)
+	bus := NewBus(TimingMiddleware)
+	RegisterCommand(bus, CreateItemCommand)
+
+	_, err := Dispatch(context.Background(), bus, createItem{Name: "book"})
+
+	require.NoError(t, err)
+	assert.Contains(t, output.String(), `msg="item created" command=CreateItemCommand`)
+	assert.Contains(t, output.String(), `msg="handled command" command=CreateItemCommand code=201`)
+}
+
+type itemService struct{}
+
+func (itemService) CreateItemCommand(ctx context.Context, cmd createItem) (int, error) {
+	return http.StatusCreated, nil
+}
+
+func TestBus_handlerNames(t *testing.T) {
+	names := []string{}
+	bus := NewBus(func(ctx context.Context, call BusCall, next func(ctx context.Context) (int, error)) (int, error) {
+		names = append(names, call.Name)
+		return next(ctx)
+	})
+	RegisterCommand(bus, itemService{}.CreateItemCommand)
+	RegisterQuery(bus, func(ctx context.Context, query getItem) (string, int, error) { return "", http.StatusOK, nil })
+
+	_, err := Dispatch(context.Background(), bus, createItem{Name: "book"})
+	require.NoError(t, err)
+	_, _, err = Ask[string](context.Background(), bus, getItem{Name: "book"})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"CreateItemCommand", "GetItemQuery"}, names, "method values and closures are named after the input type")
+}
+
+func TestAsk_resultTypeMismatch(t *testing.T) {
+	bus := NewBus()
+	RegisterQuery(bus, GetItemQuery)
+
+	result, code, err := Ask[int](context.Background(), bus, getItem{Name: "book"})
+
+	assert.ErrorIs(t, err, ErrBusResultType)
+	assert.Equal(t, http.StatusInternalServerError, code)
+	assert.Zero(t, result)
+}
+
+func TestHTTPStatus(t *testing.T) {
+	assert.Equal(t, http.StatusNotFound, HTTPStatus(http.StatusNotFound, errors.New("not found")))
+	assert.Equal(t, http.StatusInternalServerError, HTTPStatus(http.StatusOK, errors.New("failed")), "errors are never successes")
+	assert.Equal(t, http.StatusInternalServerError, HTTPStatus(0, errors.New("failed")))
+	assert.Equal(t, http.StatusOK, HTTPStatus(0, nil))
+}
+
+func TestCommandHTTP(t *testing.T) {
+	bus := NewBus()
+	RegisterCommand(bus, CreateItemCommand)
+	handler := CommandHTTP(bus, func(r *http.Request) (createItem, error) {
+		return createItem{Name: r.FormValue("name")}, nil
+	}, func(w http.ResponseWriter, r *http.Request) {
+		Redirect(w, r, "/items", http.StatusSeeOther)
+	})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/items?name=book", nil))
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, "/items", w.Header().Get("Location"))
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/items", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "name is required\n", w.Body.String())
+}
+
+func TestQueryHTTP(t *testing.T) {
+	bus := NewBus()
+	RegisterQuery(bus, GetItemQuery)
+	RegisterQuery(bus, func(ctx context.Context, query createItem) (string, int, error) {
+		return "", 0, errors.New("database is down")
+	})
+	handler := func(decode func(r *http.Request) (any, error)) http.Handler {
+		return QueryHTTP(bus, decode, func(w http.ResponseWriter, r *http.Request, result string) {
+			_, _ = w.Write([]byte(result))
+		})
+	}
+	get := handler(func(r *http.Request) (any, error) { return getItem{Name: r.PathValue("name")}, nil })
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/items/book", nil)
+	r.SetPathValue("name", "book")
+	get.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "item book", w.Body.String())
+
+	w = httptest.NewRecorder()
+	r.SetPathValue("name", "missing")
+	get.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	WriteError(context.Background(), w, http.StatusOK, errors.New("failed"))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	w = httptest.NewRecorder()
+	handler(func(r *http.Request) (any, error) { return createItem{}, nil }).ServeHTTP(w, r)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "Internal Server Error\n", w.Body.String(), "server errors must not be exposed")
+}
+
+func TestTransactionMiddleware_panic(t *testing.T) {
+	db := openSQLite(t, `CREATE TABLE items (name TEXT NOT NULL)`)
+	bus := NewBus(TransactionMiddleware(db))
+	RegisterCommand(bus, func(ctx context.Context, cmd createItem) (int, error) {
+		tx, ok := Tx(ctx)
+		require.True(t, ok)
+		_, err := tx.ExecContext(ctx, `INSERT INTO items (name) VALUES (?)`, cmd.Name)
+		require.NoError(t, err)
+		panic("handler bug")
+	})
+
+	assert.Panics(t, func() { _, _ = Dispatch(context.Background(), bus, createItem{Name: "book"}) })
+
+	// The single connection of the database is only available again once the transaction is rolled back
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var count int
+	require.NoError(t, db.QueryRowContext(ctx, `SELECT COUNT(*) FROM items`).Scan(&count))
+	assert.Equal(t, 0, count)
+}
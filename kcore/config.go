@@ -0,0 +1,323 @@
+package kcore
+
+import (
+	"encoding"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrInvalidConfig      = errors.New("invalid config")
+	ErrMissingConfigValue = errors.New("missing value")
+)
+
+const redacted = "<redacted>"
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	bytesType           = reflect.TypeOf([]byte(nil))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// ConfigLoader fills a config struct from, in increasing order of precedence:
+// the `default` tags, the YAML files, the environment variables and the command line flags.
+//
+// Fields are named with the `config:"name"` tag, or the field name. Nested structs use dotted names.
+// For a field named databaseUrl, with EnvPrefix "APP_":
+//   - the YAML key is databaseUrl
+//   - the environment variable is APP_DATABASE_URL, or APP_DATABASE_URL_FILE to read the value from a file (Docker secrets)
+//   - the flag is --databaseUrl, with the `usage` tag as help
+//
+// Fields are validated with the `validate` tag, like forms. Fields tagged `secret:"true"` are redacted by Print.
+// Supported types are those of forms, time.Duration, []byte (hex encoded) and encoding.TextUnmarshaler such as ID.
+type ConfigLoader struct {
+	EnvPrefix string
+	// Missing files are ignored, later files override earlier ones
+	Files     []string
+	Args      []string
+	LookupEnv func(key string) (string, bool) // injectable environment
+
+	sources map[string]string
+}
+
+// NewConfigLoader creates a loader reading the process environment and command line
+func NewConfigLoader(envPrefix string, files ...string) *ConfigLoader {
+	return &ConfigLoader{EnvPrefix: envPrefix, Files: files, Args: os.Args[1:], LookupEnv: os.LookupEnv}
+}
+
+type configField struct {
+	name  string
+	value reflect.Value
+	field reflect.StructField
+}
+
+func configFields(value reflect.Value, prefix string) []configField {
+	fields := []configField{}
+	for i := range value.NumField() {
+		field := value.Type().Field(i)
+		name := field.Tag.Get("config")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		name = prefix + name
+		if field.Type.Kind() == reflect.Struct && field.Type != timeType && !reflect.PointerTo(field.Type).Implements(textUnmarshalerType) {
+			fields = append(fields, configFields(value.Field(i), name+".")...)
+			continue
+		}
+		fields = append(fields, configField{name: name, value: value.Field(i), field: field})
+	}
+	return fields
+}
+
+// envName converts a config name to an environment variable name: db.maxOpenConns becomes DB_MAX_OPEN_CONNS
+func envName(name string) string {
+	var builder strings.Builder
+	var previous rune
+	for _, r := range name {
+		switch {
+		case r == '.' || r == '-':
+			builder.WriteRune('_')
+		case unicode.IsUpper(r) && (unicode.IsLower(previous) || unicode.IsDigit(previous)):
+			builder.WriteRune('_')
+			builder.WriteRune(r)
+		default:
+			builder.WriteRune(unicode.ToUpper(r))
+		}
+		previous = r
+	}
+	return builder.String()
+}
+
+// Load fills dst, which must be a pointer to a struct, and validates it.
+// All invalid values are reported in the returned error.
+func (l *ConfigLoader) Load(dst any) error {
+	value := reflect.ValueOf(dst)
+	Assert(value.Kind() == reflect.Pointer && value.Elem().Kind() == reflect.Struct, "config destination must be a pointer to a struct")
+	fields := configFields(value.Elem(), "")
+	l.sources = map[string]string{}
+
+	var errs []error
+	set := func(field configField, raw []string, source string) {
+		if err := decodeConfigValue(field.value, raw); err != nil {
+			errs = append(errs, fmt.Errorf("%w: %s from %s: %w", ErrInvalidConfig, field.name, source, err))
+			return
+		}
+		l.sources[field.name] = source
+	}
+
+	for _, field := range fields {
+		if raw, ok := field.field.Tag.Lookup("default"); ok {
+			set(field, splitConfigList(field, raw), "default")
+		}
+	}
+
+	for _, file := range l.Files {
+		values, err := readYAMLConfig(file)
+		if err != nil {
+			return err
+		}
+		for _, field := range fields {
+			if raw, ok := values[field.name]; ok {
+				set(field, raw, file)
+				delete(values, field.name)
+			}
+		}
+		for name := range values {
+			errs = append(errs, fmt.Errorf("%w: unknown key %s in %s", ErrInvalidConfig, name, file))
+		}
+	}
+
+	for _, field := range fields {
+		env := l.EnvPrefix + envName(field.name)
+		if raw, ok := l.LookupEnv(env); ok {
+			set(field, splitConfigList(field, raw), "env "+env)
+		} else if path, ok := l.LookupEnv(env + "_FILE"); ok {
+			content, err := os.ReadFile(path) // #nosec G304 path from the environment
+			if err != nil {
+				errs = append(errs, Wrap(err, "error reading "+env+"_FILE"))
+				continue
+			}
+			set(field, splitConfigList(field, strings.TrimRight(string(content), "\r\n")), "file "+path)
+		}
+	}
+
+	flags, err := l.parseFlags(fields)
+	if err != nil {
+		return err
+	}
+	for _, field := range fields {
+		if flags.Changed(field.name) {
+			raw := []string{flags.Lookup(field.name).Value.String()}
+			if field.value.Kind() == reflect.Slice && field.value.Type() != bytesType {
+				raw, _ = flags.GetStringSlice(field.name)
+			}
+			set(field, raw, "flag")
+		}
+	}
+
+	formErrs := FormErrors{}
+	for _, field := range fields {
+		_, present := l.sources[field.name]
+		if err := validateField(field.value, field.name, field.field.Tag.Get("validate"), present, formErrs); err != nil {
+			errs = append(errs, fmt.Errorf("%w: %s: %w", ErrInvalidConfig, field.name, err))
+		}
+	}
+	if len(formErrs) > 0 {
+		errs = append(errs, fmt.Errorf("%w: %w", ErrInvalidConfig, formErrs))
+	}
+	return errors.Join(errs...)
+}
+
+// splitConfigList splits the comma separated values of slices, from defaults and the environment
+func splitConfigList(field configField, raw string) []string {
+	if field.value.Kind() == reflect.Slice && field.value.Type() != bytesType {
+		return strings.Split(raw, ",")
+	}
+	return []string{raw}
+}
+
+func (l *ConfigLoader) parseFlags(fields []configField) (*pflag.FlagSet, error) {
+	flags := pflag.NewFlagSet(os.Args[0], pflag.ContinueOnError)
+	for _, field := range fields {
+		usage := field.field.Tag.Get("usage")
+		switch {
+		case field.value.Kind() == reflect.Bool:
+			flags.Bool(field.name, false, usage)
+		case field.value.Kind() == reflect.Slice && field.value.Type() != bytesType:
+			flags.StringSlice(field.name, nil, usage)
+		default:
+			flags.String(field.name, field.field.Tag.Get("default"), usage)
+		}
+	}
+	if err := flags.Parse(l.Args); err != nil {
+		return nil, Wrap(err, "error parsing flags")
+	}
+	return flags, nil
+}
+
+// readYAMLConfig returns the raw scalar values of the file by dotted name, keeping their exact text (e.g. hex secrets)
+func readYAMLConfig(file string) (map[string][]string, error) {
+	content, err := os.ReadFile(file) // #nosec G304 config file chosen by the app
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string][]string{}, nil
+	}
+	if err != nil {
+		return nil, Wrap(err, "error reading config file")
+	}
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, Wrap(err, "error parsing config file "+file)
+	}
+	values := map[string][]string{}
+	if len(document.Content) > 0 {
+		flattenYAML(document.Content[0], "", values)
+	}
+	return values, nil
+}
+
+func flattenYAML(node *yaml.Node, prefix string, values map[string][]string) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			flattenYAML(node.Content[i+1], prefix+node.Content[i].Value+".", values)
+		}
+	case yaml.SequenceNode:
+		items := make([]string, len(node.Content))
+		for i, item := range node.Content {
+			items[i] = item.Value
+		}
+		values[strings.TrimSuffix(prefix, ".")] = items
+	default:
+		values[strings.TrimSuffix(prefix, ".")] = []string{node.Value}
+	}
+}
+
+func decodeConfigValue(value reflect.Value, raw []string) error {
+	switch {
+	// An empty sequence, such as "addr: []", is only a value for lists
+	case len(raw) == 0 && (value.Kind() != reflect.Slice || value.Type() == bytesType):
+		return ErrMissingConfigValue
+	case value.Type() == durationType:
+		duration, err := time.ParseDuration(raw[0])
+		if err != nil {
+			return err
+		}
+		value.SetInt(int64(duration))
+	case value.Type() == bytesType:
+		content, err := hex.DecodeString(raw[0])
+		if err != nil {
+			return err
+		}
+		value.SetBytes(content)
+	case value.Kind() == reflect.Slice:
+		slice := reflect.MakeSlice(value.Type(), len(nonEmpty(raw)), len(nonEmpty(raw)))
+		for i, item := range nonEmpty(raw) {
+			if err := decodeConfigValue(slice.Index(i), []string{strings.TrimSpace(item)}); err != nil {
+				return err
+			}
+		}
+		value.Set(slice)
+	case raw[0] == "":
+		value.SetZero()
+	default:
+		return decodeValue(value, raw[0])
+	}
+	return nil
+}
+
+func formatConfigValue(value reflect.Value) string {
+	switch {
+	case value.Type() == durationType:
+		return time.Duration(value.Int()).String()
+	case value.Type() == bytesType:
+		return hex.EncodeToString(value.Bytes())
+	case value.Type() == timeType:
+		return value.Interface().(time.Time).Format(time.RFC3339) //nolint:forcetypeassert
+	}
+	if marshaler, ok := value.Interface().(encoding.TextMarshaler); ok {
+		text, err := marshaler.MarshalText()
+		if err == nil {
+			return string(text)
+		}
+	}
+	if value.Kind() == reflect.Slice {
+		items := make([]string, value.Len())
+		for i := range value.Len() {
+			items[i] = formatConfigValue(value.Index(i))
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(value.Interface())
+}
+
+// Print writes the effective config loaded in cfg, with the source of each value and secrets redacted
+func (l *ConfigLoader) Print(w io.Writer, cfg any) error {
+	value := reflect.Indirect(reflect.ValueOf(cfg))
+	for _, field := range configFields(value, "") {
+		formatted := formatConfigValue(field.value)
+		if field.field.Tag.Get("secret") == "true" && !field.value.IsZero() {
+			formatted = redacted
+		}
+		source, ok := l.sources[field.name]
+		if !ok {
+			source = "unset"
+		}
+		if _, err := fmt.Fprintf(w, "%s=%s (%s)\n", field.name, formatted, source); err != nil {
+			return Wrap(err, "error printing config")
+		}
+	}
+	return nil
+}
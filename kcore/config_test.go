@@ -0,0 +1,135 @@
+package kcore
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testConfig struct {
+	Addr            string        `config:"addr" default:":8080" usage:"Listen address"`
+	Debug           bool          `config:"debug"`
+	ShutdownTimeout time.Duration `config:"shutdownTimeout" default:"10s"`
+	CookieSecret    []byte        `config:"cookieSecret" validate:"required" secret:"true"`
+	AdminID         ID            `config:"adminId"`
+	Origins         []string      `config:"origins" default:"http://localhost"`
+	Database        struct {
+		URL          string `config:"url" validate:"required" secret:"true"`
+		MaxOpenConns int    `config:"maxOpenConns" default:"10" validate:"min=1"`
+	} `config:"db"`
+}
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func testLoader(env map[string]string, args []string, files ...string) *ConfigLoader {
+	return &ConfigLoader{EnvPrefix: "APP_", Files: files, Args: args, LookupEnv: func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}}
+}
+
+func TestEnvName(t *testing.T) {
+	assert.Equal(t, "SHUTDOWN_TIMEOUT", envName("shutdownTimeout"))
+	assert.Equal(t, "DB_MAX_OPEN_CONNS", envName("db.maxOpenConns"))
+	assert.Equal(t, "OAUTH2_CLIENT", envName("oauth2Client"))
+}
+
+func TestConfigLoader_precedence(t *testing.T) {
+	adminID := NewID()
+	file := writeConfigFile(t, `
+addr: ":9000"
+cookieSecret: 00112233
+origins: [https://a.example, https://b.example]
+db:
+  url: postgres://file
+  maxOpenConns: 20
+`)
+	loader := testLoader(map[string]string{
+		"APP_DB_URL":   "postgres://env",
+		"APP_ADMIN_ID": adminID.String(),
+	}, []string{"--db.maxOpenConns=30", "--debug"}, file, filepath.Join(t.TempDir(), "missing.yaml"))
+	var config testConfig
+
+	require.NoError(t, loader.Load(&config))
+
+	assert.Equal(t, ":9000", config.Addr)
+	assert.True(t, config.Debug)
+	assert.Equal(t, 10*time.Second, config.ShutdownTimeout)
+	assert.Equal(t, []byte{0x00, 0x11, 0x22, 0x33}, config.CookieSecret)
+	assert.Equal(t, adminID, config.AdminID)
+	assert.Equal(t, []string{"https://a.example", "https://b.example"}, config.Origins)
+	assert.Equal(t, "postgres://env", config.Database.URL)
+	assert.Equal(t, 30, config.Database.MaxOpenConns)
+}
+
+func TestConfigLoader_secretFile(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "cookie_secret")
+	require.NoError(t, os.WriteFile(secretFile, []byte("aabb\n"), 0o600))
+	loader := testLoader(map[string]string{
+		"APP_COOKIE_SECRET_FILE": secretFile,
+		"APP_DB_URL":             "postgres://env",
+	}, nil)
+	var config testConfig
+
+	require.NoError(t, loader.Load(&config))
+
+	assert.Equal(t, []byte{0xaa, 0xbb}, config.CookieSecret)
+}
+
+func TestConfigLoader_errors(t *testing.T) {
+	file := writeConfigFile(t, "shutdownTimeout: soon\nunknown: 1\n")
+	loader := testLoader(map[string]string{"APP_DB_MAX_OPEN_CONNS": "0"}, nil, file)
+	var config testConfig
+
+	err := loader.Load(&config)
+
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+	assert.ErrorContains(t, err, "shutdownTimeout from "+file)
+	assert.ErrorContains(t, err, "unknown key unknown")
+	assert.ErrorContains(t, err, "cookieSecret: This field is required")
+	assert.ErrorContains(t, err, "db.url: This field is required")
+	assert.ErrorContains(t, err, "db.maxOpenConns: Must be at least 1")
+}
+
+func TestConfigLoader_emptySequence(t *testing.T) {
+	file := writeConfigFile(t, "addr: []\nshutdownTimeout: []\ncookieSecret: []\norigins: []\ndb:\n  url: postgres://localhost\n")
+	loader := testLoader(nil, nil, file)
+	var config testConfig
+
+	err := loader.Load(&config)
+
+	assert.ErrorIs(t, err, ErrMissingConfigValue)
+	assert.ErrorContains(t, err, "addr from "+file)
+	assert.ErrorContains(t, err, "shutdownTimeout from "+file)
+	assert.ErrorContains(t, err, "cookieSecret from "+file)
+	assert.NotContains(t, err.Error(), "origins", "lists may be empty")
+}
+
+func TestConfigLoader_Print(t *testing.T) {
+	loader := testLoader(map[string]string{"APP_COOKIE_SECRET": "aabb", "APP_DB_URL": "postgres://user:password@db"}, []string{"--addr=:80"})
+	var config testConfig
+	require.NoError(t, loader.Load(&config))
+	var output strings.Builder
+
+	require.NoError(t, loader.Print(&output, config))
+
+	assert.Equal(t, `addr=:80 (flag)
+debug=false (unset)
+shutdownTimeout=10s (default)
+cookieSecret=<redacted> (env APP_COOKIE_SECRET)
+adminId=AAAAAAAAAAAAAAAAAAAAAA (unset)
+origins=http://localhost (default)
+db.url=<redacted> (env APP_DB_URL)
+db.maxOpenConns=10 (default)
+`, output.String())
+}
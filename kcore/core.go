@@ -1,8 +1,17 @@
 package kcore
 
 import (
+	"database/sql/driver"
 	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
 
+	"github.com/a-h/templ"
 	"github.com/gofrs/uuid"
 )
 
@@ -10,18 +19,98 @@ type ID struct {
 	uuid.UUID
 }
 
+// UUID versions supported by IDGenerator.
+// Version 7 IDs are ordered by creation time, which keeps B-tree indexes compact.
+const (
+	IDv4 = uuid.V4
+	IDv7 = uuid.V7
+)
+
+// An IDGenerator creates IDs of a given UUID version
+type IDGenerator struct {
+	version byte
+	gen     *uuid.Gen
+}
+
+// NewIDGenerator creates a generator, with injectable clock and entropy source to get deterministic IDs in tests.
+// A nil now or random uses the system clock or crypto/rand.
+func NewIDGenerator(version byte, now func() time.Time, random io.Reader) *IDGenerator {
+	Assert(version == IDv4 || version == IDv7, fmt.Sprintf("unsupported uuid version %d", version))
+	opts := []uuid.GenOption{}
+	if now != nil {
+		opts = append(opts, uuid.WithEpochFunc(now))
+	}
+	if random != nil {
+		opts = append(opts, uuid.WithRandomReader(random))
+	}
+	return &IDGenerator{version: version, gen: uuid.NewGenWithOptions(opts...)}
+}
+
+func (g *IDGenerator) NewID() ID {
+	var id uuid.UUID
+	var err error
+	switch g.version {
+	case IDv7:
+		id, err = g.gen.NewV7()
+	default:
+		id, err = g.gen.NewV4()
+	}
+	Expect(err, "error generating uuid")
+
+	return ID{id}
+}
+
+var idGenerator atomic.Pointer[IDGenerator]
+
+func init() {
+	idGenerator.Store(NewIDGenerator(IDv4, nil, nil))
+}
+
+// SetIDGenerator changes the generator used by NewID, e.g. to switch to time-ordered IDs globally
+func SetIDGenerator(g *IDGenerator) {
+	idGenerator.Store(g)
+}
+
 func NewID() ID {
-	id, err := uuid.NewV4()
+	return idGenerator.Load().NewID()
+}
+
+// NewIDv7 creates a time-ordered ID, regardless of the global generator
+func NewIDv7() ID {
+	id, err := uuid.NewV7()
 	Expect(err, "error generating uuid")
 
 	return ID{id}
 }
 
+// Time returns the creation time embedded in version 7 IDs, with millisecond precision
+func (id ID) Time() (time.Time, bool) {
+	if id.Version() != IDv7 {
+		return time.Time{}, false
+	}
+	var ms [8]byte
+	copy(ms[2:], id.Bytes()[:6])
+	return time.UnixMilli(int64(binary.BigEndian.Uint64(ms[:]))), true // #nosec G115 48 bits
+}
+
 func (id ID) String() string {
 	return base64.RawURLEncoding.EncodeToString(id.Bytes())
 }
 
+// Format prints the String form with fmt and slog, instead of the hyphenated form of the embedded uuid.UUID
+func (id ID) Format(f fmt.State, verb rune) {
+	_, _ = fmt.Fprintf(f, fmt.FormatString(f, verb), id.String())
+}
+
+// ParseID accepts the base64url form returned by String, and the canonical hyphenated UUID form
 func ParseID(value string) (ID, error) {
+	if len(value) != base64.RawURLEncoding.EncodedLen(uuid.Size) {
+		id, err := uuid.FromString(value)
+		if err != nil {
+			return ID{}, Wrap(err, "error parsing uuid")
+		}
+		return ID{id}, nil
+	}
 	bytes, err := base64.RawURLEncoding.DecodeString(value)
 	if err != nil {
 		return ID{}, Wrap(err, "error decoding value")
@@ -32,3 +121,98 @@ func ParseID(value string) (ID, error) {
 	}
 	return ID{id}, nil
 }
+
+// MarshalText uses the base64url form, so that JSON and URLs agree
+func (id ID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+func (id *ID) UnmarshalText(text []byte) error {
+	parsed, err := ParseID(string(text))
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
+
+// Value stores the canonical UUID form, understood by native database uuid types
+func (id ID) Value() (driver.Value, error) {
+	return id.UUID.String(), nil
+}
+
+// Scan accepts raw 16 bytes, and the base64url or canonical text forms
+func (id *ID) Scan(src any) error {
+	switch src := src.(type) {
+	case []byte:
+		if len(src) == uuid.Size {
+			return id.UUID.UnmarshalBinary(src)
+		}
+		return id.UnmarshalText(src)
+	case string:
+		return id.UnmarshalText([]byte(src))
+	}
+	return fmt.Errorf("cannot scan %T into kcore.ID", src)
+}
+
+// URL formats a templ safe URL with IDs in their base64url form: kcore.URL("/items/%s", item.ID)
+func URL(format string, ids ...ID) templ.SafeURL {
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id.String()
+	}
+	return templ.SafeURL(fmt.Sprintf(format, args...)) // #nosec G203 IDs are url safe
+}
+
+// PathID parses the ID from a path value of the request pattern
+func PathID(r *http.Request, name string) (ID, error) {
+	return ParseID(r.PathValue(name))
+}
+
+// NullID represents an ID that can be NULL in the database, or null in JSON
+type NullID struct {
+	ID    ID
+	Valid bool
+}
+
+func (n NullID) Value() (driver.Value, error) {
+	if !n.Valid {
+		return nil, nil
+	}
+	return n.ID.Value()
+}
+
+func (n *NullID) Scan(src any) error {
+	if src == nil {
+		*n = NullID{}
+		return nil
+	}
+	var id ID
+	if err := id.Scan(src); err != nil {
+		*n = NullID{}
+		return err
+	}
+	*n = NullID{ID: id, Valid: true}
+	return nil
+}
+
+func (n NullID) MarshalJSON() ([]byte, error) {
+	if !n.Valid {
+		return []byte("null"), nil
+	}
+	return json.Marshal(n.ID)
+}
+
+func (n *NullID) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*n = NullID{}
+		return nil
+	}
+	var id ID
+	if err := json.Unmarshal(data, &id); err != nil {
+		*n = NullID{}
+		return err
+	}
+	*n = NullID{ID: id, Valid: true}
+	return nil
+}
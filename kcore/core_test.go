@@ -0,0 +1,161 @@
+package kcore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/a-h/templ"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIDGenerator_v7Deterministic(t *testing.T) {
+	now := time.Date(2026, time.May, 12, 10, 30, 0, 0, time.UTC)
+	newGenerator := func() *IDGenerator {
+		return NewIDGenerator(IDv7, func() time.Time { return now }, bytes.NewReader(bytes.Repeat([]byte{0x42}, 64)))
+	}
+
+	first := newGenerator().NewID()
+	second := newGenerator().NewID()
+
+	assert.Equal(t, first, second)
+	createdAt, ok := first.Time()
+	assert.True(t, ok)
+	assert.True(t, now.Equal(createdAt))
+}
+
+func TestIDGenerator_v7Sortable(t *testing.T) {
+	now := time.Date(2026, time.May, 12, 10, 30, 0, 0, time.UTC)
+	generator := NewIDGenerator(IDv7, func() time.Time { return now }, nil)
+
+	ids := make([]ID, 0, 10)
+	for range 5 {
+		ids = append(ids, generator.NewID(), generator.NewID())
+		now = now.Add(time.Millisecond)
+	}
+
+	for i := 1; i < len(ids); i++ {
+		assert.Negative(t, bytes.Compare(ids[i-1].Bytes(), ids[i].Bytes()))
+	}
+}
+
+func TestSetIDGenerator(t *testing.T) {
+	t.Cleanup(func() { SetIDGenerator(NewIDGenerator(IDv4, nil, nil)) })
+
+	SetIDGenerator(NewIDGenerator(IDv7, nil, nil))
+
+	assert.Equal(t, IDv7, NewID().Version())
+}
+
+func TestID_v4HasNoTime(t *testing.T) {
+	_, ok := NewIDGenerator(IDv4, nil, nil).NewID().Time()
+
+	assert.False(t, ok)
+}
+
+func TestParseID_v7RoundTrip(t *testing.T) {
+	id := NewIDv7()
+
+	parsed, err := ParseID(id.String())
+
+	require.NoError(t, err)
+	assert.Equal(t, id, parsed)
+	assert.Len(t, id.String(), 22)
+}
+
+func TestParseID_canonical(t *testing.T) {
+	id := NewID()
+
+	parsed, err := ParseID(id.UUID.String())
+
+	require.NoError(t, err)
+	assert.Equal(t, id, parsed)
+}
+
+func TestID_Format(t *testing.T) {
+	id := NewID()
+
+	assert.Equal(t, id.String(), fmt.Sprint(id))
+	assert.Equal(t, "/items/"+id.String(), fmt.Sprintf("/items/%s", id))
+	assert.Equal(t, `"`+id.String()+`"`, fmt.Sprintf("%q", id))
+}
+
+func TestID_JSON(t *testing.T) {
+	type item struct {
+		ID     ID     `json:"id"`
+		Parent NullID `json:"parent"`
+	}
+	original := item{ID: NewID()}
+
+	content, err := json.Marshal(original)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id":"`+original.ID.String()+`","parent":null}`, string(content))
+
+	var decoded item
+	require.NoError(t, json.Unmarshal(content, &decoded))
+	assert.Equal(t, original, decoded)
+}
+
+func TestNullID_JSON(t *testing.T) {
+	original := NullID{ID: NewID(), Valid: true}
+
+	content, err := json.Marshal(original)
+	require.NoError(t, err)
+	assert.Equal(t, `"`+original.ID.String()+`"`, string(content))
+
+	var decoded NullID
+	require.NoError(t, json.Unmarshal(content, &decoded))
+	assert.Equal(t, original, decoded)
+
+	assert.Error(t, json.Unmarshal([]byte(`"not an id"`), &decoded))
+	assert.Equal(t, NullID{}, decoded, "failed decoding is not valid")
+}
+
+func TestID_Scan(t *testing.T) {
+	id := NewID()
+	for _, src := range []any{id.Bytes(), id.UUID.String(), []byte(id.UUID.String()), id.String()} {
+		var scanned ID
+		require.NoError(t, scanned.Scan(src))
+		assert.Equal(t, id, scanned)
+	}
+
+	var scanned ID
+	assert.Error(t, scanned.Scan(42))
+}
+
+func TestID_Value(t *testing.T) {
+	id := NewID()
+
+	value, err := id.Value()
+	require.NoError(t, err)
+	assert.Equal(t, id.UUID.String(), value)
+
+	value, err = NullID{}.Value()
+	require.NoError(t, err)
+	assert.Nil(t, value)
+}
+
+func TestNullID_Scan(t *testing.T) {
+	var n NullID
+	require.NoError(t, n.Scan(nil))
+	assert.False(t, n.Valid)
+
+	id := NewID()
+	require.NoError(t, n.Scan(id.UUID.String()))
+	assert.Equal(t, NullID{ID: id, Valid: true}, n)
+
+	assert.Error(t, n.Scan("not an id"))
+	assert.Equal(t, NullID{}, n, "failed scans are not valid")
+	err := n.Scan(42)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrAssert)
+}
+
+func TestURL(t *testing.T) {
+	id := NewID()
+
+	assert.Equal(t, templ.SafeURL("/items/"+id.String()+"/edit"), URL("/items/%s/edit", id))
+}
@@ -0,0 +1,154 @@
+package kcore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var ErrVersionConflict = errors.New("aggregate version conflict")
+
+// An Event records a change of an aggregate, with the time and actor of the change
+type Event struct {
+	ID          ID
+	AggregateID ID
+	Type        string
+	// Version is the position of the event in the aggregate stream, starting at 1
+	Version int
+	// Position is the global position of the event in the store, set when appended
+	Position int64
+	Time     time.Time
+	Actor    string
+	Payload  json.RawMessage
+}
+
+// NewEvent creates an event with its payload encoded in JSON. The version is set by EventStore.Append.
+func NewEvent(aggregateID ID, eventType string, actor string, payload any) Event {
+	content, err := json.Marshal(payload)
+	Expect(err, "error marshalling event payload")
+	return Event{
+		ID:          NewID(),
+		AggregateID: aggregateID,
+		Type:        eventType,
+		Time:        time.Now(),
+		Actor:       actor,
+		Payload:     content,
+	}
+}
+
+// Decode unmarshals the event payload
+func (e Event) Decode(payload any) error {
+	if err := json.Unmarshal(e.Payload, payload); err != nil {
+		return Wrap(err, "error unmarshalling event payload")
+	}
+	return nil
+}
+
+// An EventStore is an append-only log of events
+type EventStore interface {
+	// Append adds events to the aggregate stream, failing with ErrVersionConflict
+	// if the current version of the aggregate is not expectedVersion.
+	// It returns the events as stored, with their aggregate, version and position.
+	Append(ctx context.Context, aggregateID ID, expectedVersion int, events ...Event) ([]Event, error)
+	// Load returns the events of an aggregate, ordered by version
+	Load(ctx context.Context, aggregateID ID) ([]Event, error)
+	// LoadAll returns the events of all aggregates after the given position, ordered by position
+	LoadAll(ctx context.Context, after int64) ([]Event, error)
+}
+
+// An Aggregate is rebuilt by applying its events in order
+type Aggregate interface {
+	Apply(event Event) error
+}
+
+// Rehydrate applies the stored events to the aggregate, and returns its version to use in EventStore.Append
+func Rehydrate(ctx context.Context, store EventStore, aggregateID ID, aggregate Aggregate) (int, error) {
+	events, err := store.Load(ctx, aggregateID)
+	if err != nil {
+		return 0, Wrap(err, "error loading events")
+	}
+	version := 0
+	for _, event := range events {
+		if err := aggregate.Apply(event); err != nil {
+			return version, Wrap(err, fmt.Sprintf("error applying event %s version %d", event.Type, event.Version))
+		}
+		version = event.Version
+	}
+	return version, nil
+}
+
+// A Subscriber handles events after they are appended
+type Subscriber interface {
+	Handle(ctx context.Context, event Event) error
+}
+
+type SubscriberFunc func(ctx context.Context, event Event) error
+
+func (f SubscriberFunc) Handle(ctx context.Context, event Event) error {
+	return f(ctx, event)
+}
+
+// A Projection is a read model built from events, that can be rebuilt from scratch
+type Projection interface {
+	Subscriber
+	Reset(ctx context.Context) error
+}
+
+// EventBus appends events to the store, then notifies subscribers in registration order
+type EventBus struct {
+	store       EventStore
+	mutex       sync.RWMutex
+	subscribers []Subscriber
+}
+
+func NewEventBus(store EventStore) *EventBus {
+	return &EventBus{store: store}
+}
+
+func (b *EventBus) Subscribe(subscriber Subscriber) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.subscribers = append(b.subscribers, subscriber)
+}
+
+// Append stores the events, then notifies subscribers of the events as stored, with their version and position.
+// Subscribers errors are returned, but the events stay stored: replay projections to recover.
+func (b *EventBus) Append(ctx context.Context, aggregateID ID, expectedVersion int, events ...Event) error {
+	stored, err := b.store.Append(ctx, aggregateID, expectedVersion, events...)
+	if err != nil {
+		return err
+	}
+	b.mutex.RLock()
+	subscribers := b.subscribers
+	b.mutex.RUnlock()
+
+	var errs []error
+	for _, event := range stored {
+		for _, subscriber := range subscribers {
+			if err := subscriber.Handle(ctx, event); err != nil {
+				errs = append(errs, Wrap(err, "error handling event "+event.Type))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Replay resets the projection, and feeds it every stored event
+func (b *EventBus) Replay(ctx context.Context, projection Projection) error {
+	if err := projection.Reset(ctx); err != nil {
+		return Wrap(err, "error resetting projection")
+	}
+	events, err := b.store.LoadAll(ctx, 0)
+	if err != nil {
+		return Wrap(err, "error loading events")
+	}
+	for _, event := range events {
+		if err := projection.Handle(ctx, event); err != nil {
+			return Wrap(err, "error handling event "+event.Type)
+		}
+	}
+	return nil
+}
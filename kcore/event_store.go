@@ -0,0 +1,159 @@
+package kcore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// MemoryEventStore keeps events in memory, for tests and prototypes
+type MemoryEventStore struct {
+	mutex      sync.RWMutex
+	events     []Event
+	aggregates map[ID][]int
+}
+
+func NewMemoryEventStore() *MemoryEventStore {
+	return &MemoryEventStore{aggregates: map[ID][]int{}}
+}
+
+func (s *MemoryEventStore) Append(ctx context.Context, aggregateID ID, expectedVersion int, events ...Event) ([]Event, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if current := len(s.aggregates[aggregateID]); current != expectedVersion {
+		return nil, fmt.Errorf("%w: expected version %d, current version %d", ErrVersionConflict, expectedVersion, current)
+	}
+	stored := make([]Event, len(events))
+	for i, event := range events {
+		event.AggregateID = aggregateID
+		event.Version = expectedVersion + i + 1
+		event.Position = int64(len(s.events) + 1)
+		s.aggregates[aggregateID] = append(s.aggregates[aggregateID], len(s.events))
+		s.events = append(s.events, event)
+		stored[i] = event
+	}
+	return stored, nil
+}
+
+func (s *MemoryEventStore) Load(ctx context.Context, aggregateID ID) ([]Event, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	events := make([]Event, len(s.aggregates[aggregateID]))
+	for i, index := range s.aggregates[aggregateID] {
+		events[i] = s.events[index]
+	}
+	return events, nil
+}
+
+func (s *MemoryEventStore) LoadAll(ctx context.Context, after int64) ([]Event, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	after = max(after, 0)
+	if after >= int64(len(s.events)) {
+		return []Event{}, nil
+	}
+	return append([]Event{}, s.events[after:]...), nil
+}
+
+// SQLEventStore stores events in an events table:
+//
+//	CREATE TABLE events (
+//	  position INTEGER PRIMARY KEY AUTOINCREMENT, -- BIGSERIAL on Postgres
+//	  id TEXT NOT NULL,
+//	  aggregate_id TEXT NOT NULL,
+//	  type TEXT NOT NULL,
+//	  version INTEGER NOT NULL,
+//	  time TIMESTAMP NOT NULL,
+//	  actor TEXT NOT NULL,
+//	  payload TEXT NOT NULL,
+//	  UNIQUE (aggregate_id, version)
+//	);
+type SQLEventStore struct {
+	DB      *sql.DB
+	Dialect SQLDialect
+	// UniqueViolation reports unique constraint violations for drivers without error codes known to the dialect,
+	// such as mattn/go-sqlite3 or MySQL
+	UniqueViolation func(err error) bool
+}
+
+func (s SQLEventStore) Append(ctx context.Context, aggregateID ID, expectedVersion int, events ...Event) ([]Event, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, Wrap(err, "error beginning transaction")
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op after commit
+
+	var current int
+	row := tx.QueryRowContext(ctx, s.Dialect.rebind(`SELECT COALESCE(MAX(version), 0) FROM events WHERE aggregate_id = ?`), aggregateID)
+	if err := row.Scan(&current); err != nil {
+		return nil, Wrap(err, "error reading aggregate version")
+	}
+	if current != expectedVersion {
+		return nil, fmt.Errorf("%w: expected version %d, current version %d", ErrVersionConflict, expectedVersion, current)
+	}
+	stored := make([]Event, len(events))
+	for i, event := range events {
+		event.AggregateID, event.Version, event.Time = aggregateID, expectedVersion+i+1, event.Time.UTC()
+		// The unique constraint on (aggregate_id, version) rejects concurrent appends that passed the check above
+		err := s.insert(ctx, tx, &event)
+		if err != nil && (s.Dialect.isUniqueViolation(err) || s.UniqueViolation != nil && s.UniqueViolation(err)) {
+			return nil, fmt.Errorf("%w: expected version %d, version %d appended concurrently", ErrVersionConflict, expectedVersion, event.Version)
+		}
+		if err != nil {
+			return nil, Wrap(err, "error inserting event")
+		}
+		stored[i] = event
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, Wrap(err, "error committing events")
+	}
+	return stored, nil
+}
+
+// insert stores the event and sets its position, with RETURNING on Postgres, whose drivers have no LastInsertId
+func (s SQLEventStore) insert(ctx context.Context, tx *sql.Tx, event *Event) error {
+	query := `INSERT INTO events (id, aggregate_id, type, version, time, actor, payload) VALUES (?, ?, ?, ?, ?, ?, ?)`
+	args := []any{event.ID, event.AggregateID, event.Type, event.Version, event.Time, event.Actor, string(event.Payload)}
+	if s.Dialect == Postgres {
+		return tx.QueryRowContext(ctx, s.Dialect.rebind(query+` RETURNING position`), args...).Scan(&event.Position)
+	}
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	event.Position, err = result.LastInsertId()
+	return err
+}
+
+func (s SQLEventStore) Load(ctx context.Context, aggregateID ID) ([]Event, error) {
+	return s.query(ctx, `SELECT position, id, aggregate_id, type, version, time, actor, payload FROM events WHERE aggregate_id = ? ORDER BY version`, aggregateID)
+}
+
+func (s SQLEventStore) LoadAll(ctx context.Context, after int64) ([]Event, error) {
+	return s.query(ctx, `SELECT position, id, aggregate_id, type, version, time, actor, payload FROM events WHERE position > ? ORDER BY position`, after)
+}
+
+func (s SQLEventStore) query(ctx context.Context, query string, args ...any) ([]Event, error) {
+	rows, err := s.DB.QueryContext(ctx, s.Dialect.rebind(query), args...)
+	if err != nil {
+		return nil, Wrap(err, "error querying events")
+	}
+	defer rows.Close() //nolint:errcheck
+
+	events := []Event{}
+	for rows.Next() {
+		var event Event
+		var payload string
+		if err := rows.Scan(&event.Position, &event.ID, &event.AggregateID, &event.Type, &event.Version, &event.Time, &event.Actor, &payload); err != nil {
+			return nil, Wrap(err, "error scanning event")
+		}
+		event.Payload = json.RawMessage(payload)
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, Wrap(err, "error reading events")
+	}
+	return events, nil
+}
@@ -0,0 +1,117 @@
+package kcore
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+// openSQLite opens an in-memory database with the given schema
+func openSQLite(t *testing.T, schema string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	// Every connection to :memory: is a new database
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() }) //nolint:errcheck
+	_, err = db.Exec(schema)
+	require.NoError(t, err)
+	return db
+}
+
+const eventsSchema = `CREATE TABLE events (
+	position INTEGER PRIMARY KEY AUTOINCREMENT,
+	id TEXT NOT NULL,
+	aggregate_id TEXT NOT NULL,
+	type TEXT NOT NULL,
+	version INTEGER NOT NULL,
+	time TIMESTAMP NOT NULL,
+	actor TEXT NOT NULL,
+	payload TEXT NOT NULL,
+	UNIQUE (aggregate_id, version)
+)`
+
+func TestSQLEventStore_appendAndLoad(t *testing.T) {
+	ctx := context.Background()
+	store := SQLEventStore{DB: openSQLite(t, eventsSchema)}
+	first, second := NewID(), NewID()
+	deposit := NewEvent(first, "deposited", "alice", testDeposit{Amount: 10})
+	deposit.Time = time.Date(2026, time.May, 12, 10, 0, 0, 0, time.UTC)
+	stored := appendEvents(t, store, first, 0, deposit, NewEvent(first, "deposited", "alice", testDeposit{Amount: 5}))
+	appendEvents(t, store, second, 0, NewEvent(second, "deposited", "bob", testDeposit{Amount: 3}))
+
+	events, err := store.Load(ctx, first)
+
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Len(t, stored, 2)
+	assert.Equal(t, []int64{1, 2}, []int64{stored[0].Position, stored[1].Position}, "Append returns the events as stored")
+	assert.Equal(t, []int{1, 2}, []int{stored[0].Version, stored[1].Version})
+	assert.Equal(t, first, stored[1].AggregateID)
+	assert.Equal(t, deposit.ID, events[0].ID)
+	assert.Equal(t, first, events[0].AggregateID)
+	assert.Equal(t, 1, events[0].Version)
+	assert.Equal(t, int64(1), events[0].Position)
+	assert.True(t, deposit.Time.Equal(events[0].Time))
+	assert.Equal(t, "alice", events[0].Actor)
+	assert.JSONEq(t, `{"amount": 10}`, string(events[0].Payload))
+	assert.Equal(t, 2, events[1].Version)
+
+	all, err := store.LoadAll(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	assert.Equal(t, []int64{2, 3}, []int64{all[0].Position, all[1].Position})
+}
+
+func TestSQLEventStore_versionConflict(t *testing.T) {
+	ctx := context.Background()
+	store := SQLEventStore{DB: openSQLite(t, eventsSchema)}
+	account := NewID()
+	appendEvents(t, store, account, 0, NewEvent(account, "deposited", "alice", testDeposit{Amount: 10}))
+
+	_, err := store.Append(ctx, account, 0, NewEvent(account, "deposited", "bob", testDeposit{Amount: 5}))
+
+	assert.ErrorIs(t, err, ErrVersionConflict)
+}
+
+func TestSQLEventStore_concurrentAppend(t *testing.T) {
+	ctx := context.Background()
+	// A concurrent writer appends version 2 right after this append inserts version 1, after its version check
+	store := SQLEventStore{DB: openSQLite(t, eventsSchema+`;
+		CREATE TRIGGER concurrent_append AFTER INSERT ON events WHEN NEW.version = 1 BEGIN
+			INSERT INTO events (id, aggregate_id, type, version, time, actor, payload)
+			VALUES ('concurrent', NEW.aggregate_id, NEW.type, 2, NEW.time, 'bob', NEW.payload);
+		END`)}
+	account := NewID()
+
+	_, err := store.Append(ctx, account, 0,
+		NewEvent(account, "deposited", "alice", testDeposit{Amount: 10}),
+		NewEvent(account, "deposited", "alice", testDeposit{Amount: 5}),
+	)
+
+	assert.ErrorIs(t, err, ErrVersionConflict)
+	events, err := store.Load(ctx, account)
+	require.NoError(t, err)
+	assert.Empty(t, events, "the conflicting append is rolled back")
+}
+
+func TestSQLEventStore_uniqueViolationHook(t *testing.T) {
+	// Drivers without known error codes report violations through the hook
+	store := SQLEventStore{DB: openSQLite(t, eventsSchema+`;
+		CREATE TRIGGER duplicate BEFORE INSERT ON events BEGIN SELECT RAISE(ABORT, 'duplicate version'); END`)}
+	account := NewID()
+	event := NewEvent(account, "deposited", "alice", testDeposit{Amount: 10})
+
+	_, err := store.Append(context.Background(), account, 0, event)
+	assert.NotErrorIs(t, err, ErrVersionConflict)
+
+	store.UniqueViolation = func(err error) bool { return strings.Contains(err.Error(), "duplicate version") }
+	_, err = store.Append(context.Background(), account, 0, event)
+	assert.ErrorIs(t, err, ErrVersionConflict)
+}
@@ -0,0 +1,172 @@
+package kcore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testAccount struct {
+	balance int
+}
+
+type testDeposit struct {
+	Amount int `json:"amount"`
+}
+
+func (a *testAccount) Apply(event Event) error {
+	var deposit testDeposit
+	if err := event.Decode(&deposit); err != nil {
+		return err
+	}
+	a.balance += deposit.Amount
+	return nil
+}
+
+type testBalances struct {
+	balances map[ID]int
+}
+
+func (p *testBalances) Reset(ctx context.Context) error {
+	p.balances = map[ID]int{}
+	return nil
+}
+
+func (p *testBalances) Handle(ctx context.Context, event Event) error {
+	var deposit testDeposit
+	if err := event.Decode(&deposit); err != nil {
+		return err
+	}
+	p.balances[event.AggregateID] += deposit.Amount
+	return nil
+}
+
+// appendEvents appends to the store directly, bypassing the bus, and returns the stored events
+func appendEvents(t *testing.T, store EventStore, aggregateID ID, expectedVersion int, events ...Event) []Event {
+	t.Helper()
+	stored, err := store.Append(context.Background(), aggregateID, expectedVersion, events...)
+	require.NoError(t, err)
+	return stored
+}
+
+func TestMemoryEventStore_versionConflict(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryEventStore()
+	account := NewID()
+	appendEvents(t, store, account, 0, NewEvent(account, "deposited", "alice", testDeposit{Amount: 10}))
+
+	_, err := store.Append(ctx, account, 0, NewEvent(account, "deposited", "bob", testDeposit{Amount: 5}))
+
+	assert.ErrorIs(t, err, ErrVersionConflict)
+}
+
+func TestRehydrate(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryEventStore()
+	account := NewID()
+	appendEvents(t, store, account, 0,
+		NewEvent(account, "deposited", "alice", testDeposit{Amount: 10}),
+		NewEvent(account, "deposited", "alice", testDeposit{Amount: 5}),
+	)
+	appendEvents(t, store, NewID(), 0, NewEvent(account, "deposited", "bob", testDeposit{Amount: 100}))
+
+	var aggregate testAccount
+	version, err := Rehydrate(ctx, store, account, &aggregate)
+
+	require.NoError(t, err)
+	assert.Equal(t, 2, version)
+	assert.Equal(t, 15, aggregate.balance)
+}
+
+func TestEventBus_subscribers(t *testing.T) {
+	ctx := context.Background()
+	bus := NewEventBus(NewMemoryEventStore())
+	received := []Event{}
+	bus.Subscribe(SubscriberFunc(func(ctx context.Context, event Event) error {
+		received = append(received, event)
+		return nil
+	}))
+	errSubscriber := errors.New("subscriber failed")
+	bus.Subscribe(SubscriberFunc(func(ctx context.Context, event Event) error { return errSubscriber }))
+	account := NewID()
+
+	err := bus.Append(ctx, account, 0, NewEvent(account, "deposited", "alice", testDeposit{Amount: 10}))
+
+	assert.ErrorIs(t, err, errSubscriber)
+	require.Len(t, received, 1)
+	assert.Equal(t, 1, received[0].Version)
+	assert.Equal(t, "alice", received[0].Actor)
+}
+
+func TestEventBus_publishesStoredEvents(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryEventStore()
+	appendEvents(t, store, NewID(), 0, NewEvent(NewID(), "deposited", "bob", testDeposit{Amount: 3}))
+	bus := NewEventBus(store)
+	received := []Event{}
+	bus.Subscribe(SubscriberFunc(func(ctx context.Context, event Event) error {
+		received = append(received, event)
+		return nil
+	}))
+	account := NewID()
+
+	// The event is created for another aggregate: the store assigns the appended one
+	require.NoError(t, bus.Append(ctx, account, 0, NewEvent(NewID(), "deposited", "alice", testDeposit{Amount: 10})))
+
+	require.Len(t, received, 1)
+	assert.Equal(t, account, received[0].AggregateID)
+	assert.Equal(t, int64(2), received[0].Position)
+}
+
+func TestMemoryEventStore_LoadAllNegative(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryEventStore()
+	account := NewID()
+	appendEvents(t, store, account, 0, NewEvent(account, "deposited", "alice", testDeposit{Amount: 10}))
+
+	events, err := store.LoadAll(ctx, -1)
+
+	require.NoError(t, err)
+	assert.Len(t, events, 1)
+}
+
+func TestEventBus_replay(t *testing.T) {
+	ctx := context.Background()
+	bus := NewEventBus(NewMemoryEventStore())
+	first, second := NewID(), NewID()
+	require.NoError(t, bus.Append(ctx, first, 0, NewEvent(first, "deposited", "alice", testDeposit{Amount: 10})))
+	require.NoError(t, bus.Append(ctx, second, 0, NewEvent(second, "deposited", "bob", testDeposit{Amount: 3})))
+	require.NoError(t, bus.Append(ctx, first, 1, NewEvent(first, "deposited", "alice", testDeposit{Amount: 5})))
+
+	projection := &testBalances{balances: map[ID]int{first: 1000}}
+	require.NoError(t, bus.Replay(ctx, projection))
+
+	assert.Equal(t, map[ID]int{first: 15, second: 3}, projection.balances)
+}
+
+func TestSQLDialect_rebind(t *testing.T) {
+	assert.Equal(t, "SELECT ? WHERE a = ?", SQLite.rebind("SELECT ? WHERE a = ?"))
+	assert.Equal(t, "SELECT $1 WHERE a = $2", Postgres.rebind("SELECT ? WHERE a = ?"))
+}
+
+type sqlStateError string
+
+func (e sqlStateError) Error() string    { return "sql error " + string(e) }
+func (e sqlStateError) SQLState() string { return string(e) }
+
+type sqliteError int
+
+func (e sqliteError) Error() string { return "sqlite error" }
+func (e sqliteError) Code() int     { return int(e) }
+
+func TestSQLDialect_isUniqueViolation(t *testing.T) {
+	assert.True(t, Postgres.isUniqueViolation(fmt.Errorf("insert: %w", sqlStateError("23505"))))
+	assert.False(t, Postgres.isUniqueViolation(sqlStateError("23503")))
+	assert.True(t, SQLite.isUniqueViolation(sqliteError(2067)))
+	assert.False(t, SQLite.isUniqueViolation(sqliteError(787)), "foreign key constraint")
+	assert.False(t, SQLite.isUniqueViolation(errors.New("UNIQUE constraint failed")), "messages are not matched")
+}
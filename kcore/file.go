@@ -1,10 +1,25 @@
 package kcore
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"mime/multipart"
+	"net/http"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrFileTooLarge       = errors.New("file is too large")
+	ErrFileTypeNotAllowed = errors.New("file type is not allowed")
+	ErrSharedFile         = errors.New("file may be shared by several uploads")
 )
 
 type File (string)
@@ -18,21 +33,185 @@ func (file File) Path() string {
 }
 
 func (file *File) Save(raw multipart.File) error {
-	dest, err := os.Create(file.Path())
-	if err != nil {
-		return Wrap(err, "error creating file destination")
+	return atomicWrite(file.Path(), raw)
+}
+
+// Deduplicated reports whether the file is named after its content hash by FileUploadConfig.Deduplicate
+func (file File) Deduplicated() bool {
+	name := strings.TrimSuffix(string(file), filepath.Ext(string(file)))
+	_, err := hex.DecodeString(name)
+	return len(name) == sha256.Size*2 && err == nil
+}
+
+// Delete removes the file. Deduplicated files may be shared by several uploads, so they are never deleted here:
+// Delete returns ErrSharedFile, and the app removes them with os.Remove once no record references their hash.
+func (file *File) Delete() error {
+	if file.Deduplicated() {
+		return fmt.Errorf("%w: %s", ErrSharedFile, *file)
 	}
-	_, err = io.Copy(dest, raw)
+	err := os.Remove(file.Path())
 	if err != nil {
-		return Wrap(err, "error copying to file destination")
+		return Wrap(err, "error deleting file")
 	}
 	return nil
 }
 
-func (file *File) Delete() error {
-	err := os.Remove(file.Path())
+// Mode of stored media, readable by a separate web server, as temporary files are only readable by their owner
+const mediaFileMode = 0o644
+
+// atomicWrite copies raw to a temporary file next to path, then renames it,
+// so that a failed copy never leaves a partial file at path
+func atomicWrite(path string, raw io.Reader) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
 	if err != nil {
-		return Wrap(err, "error deleting file")
+		return Wrap(err, "error creating temporary file")
+	}
+	defer os.Remove(tmp.Name()) //nolint:errcheck // no-op once renamed
+	_, err = io.Copy(tmp, raw)
+	if err == nil {
+		err = tmp.Chmod(mediaFileMode)
+	}
+	if err != nil {
+		_ = tmp.Close()
+		return Wrap(err, "error copying to temporary file")
+	}
+	if err := tmp.Close(); err != nil {
+		return Wrap(err, "error closing temporary file")
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return Wrap(err, "error renaming temporary file")
 	}
 	return nil
 }
+
+// FileUploadConfig restricts uploads. AllowedTypes are MIME types checked against the sniffed content,
+// and can use a wildcard subtype such as "image/*".
+type FileUploadConfig struct {
+	// MaxSize in bytes, 0 for no limit
+	MaxSize      int64
+	AllowedTypes []string
+	// Deduplicate names the file after its content hash, so identical uploads share a single file
+	Deduplicate bool
+}
+
+// FileMetadata describes a stored upload
+type FileMetadata struct {
+	File         File
+	OriginalName string
+	Size         int64
+	ContentType  string
+	SHA256       string
+}
+
+func (config FileUploadConfig) allows(contentType string) bool {
+	return slices.ContainsFunc(config.AllowedTypes, func(allowed string) bool {
+		if prefix, ok := strings.CutSuffix(allowed, "/*"); ok {
+			return strings.HasPrefix(contentType, prefix+"/")
+		}
+		return allowed == contentType
+	})
+}
+
+// UploadFormFile stores a file from a multipart form, see UploadFile
+func UploadFormFile(header *multipart.FileHeader, config FileUploadConfig) (FileMetadata, error) {
+	raw, err := header.Open()
+	if err != nil {
+		return FileMetadata{}, Wrap(err, "error opening form file")
+	}
+	defer raw.Close() //nolint:errcheck
+	return UploadFile(raw, header.Filename, config)
+}
+
+// UploadFile checks and stores an upload in media/files.
+// The content type is sniffed from the content rather than trusted from the client,
+// and the extension is derived from it.
+func UploadFile(raw io.Reader, originalName string, config FileUploadConfig) (FileMetadata, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(raw, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return FileMetadata{}, Wrap(err, "error reading file")
+	}
+	head = head[:n]
+	contentType, _, err := mime.ParseMediaType(http.DetectContentType(head))
+	Expect(err, "error parsing sniffed content type")
+	if !config.allows(contentType) {
+		return FileMetadata{}, fmt.Errorf("%w: %s", ErrFileTypeNotAllowed, contentType)
+	}
+
+	tmp, err := os.CreateTemp("media/files", ".upload-*")
+	if err != nil {
+		return FileMetadata{}, Wrap(err, "error creating temporary file")
+	}
+	defer os.Remove(tmp.Name()) //nolint:errcheck // no-op once renamed
+
+	hash := sha256.New()
+	content := io.MultiReader(bytes.NewReader(head), raw)
+	if config.MaxSize > 0 {
+		content = io.LimitReader(content, config.MaxSize+1)
+	}
+	size, err := io.Copy(io.MultiWriter(tmp, hash), content)
+	if err == nil {
+		err = tmp.Chmod(mediaFileMode)
+	}
+	closeErr := tmp.Close()
+	if err != nil {
+		return FileMetadata{}, Wrap(err, "error copying to temporary file")
+	}
+	if closeErr != nil {
+		return FileMetadata{}, Wrap(closeErr, "error closing temporary file")
+	}
+	if config.MaxSize > 0 && size > config.MaxSize {
+		return FileMetadata{}, fmt.Errorf("%w: more than %d bytes", ErrFileTooLarge, config.MaxSize)
+	}
+
+	metadata := FileMetadata{
+		OriginalName: filepath.Base(originalName),
+		Size:         size,
+		ContentType:  contentType,
+		SHA256:       hex.EncodeToString(hash.Sum(nil)),
+	}
+	ext := fileExtensions[contentType]
+	metadata.File = NewFile(ext)
+	if config.Deduplicate {
+		metadata.File = File(metadata.SHA256 + ext)
+		if _, err := os.Stat(metadata.File.Path()); err == nil {
+			return metadata, nil
+		}
+	}
+	if err := os.Rename(tmp.Name(), metadata.File.Path()); err != nil {
+		return FileMetadata{}, Wrap(err, "error renaming temporary file")
+	}
+	return metadata, nil
+}
+
+// Extensions of the sniffed content types, fixed rather than read from the mime tables of the host
+var fileExtensions = map[string]string{
+	"application/pdf":        ".pdf",
+	"application/postscript": ".ps",
+	"application/zip":        ".zip",
+	"application/x-gzip":     ".gz",
+	"application/ogg":        ".ogg",
+	"application/wasm":       ".wasm",
+	"audio/aiff":             ".aiff",
+	"audio/basic":            ".au",
+	"audio/midi":             ".mid",
+	"audio/mpeg":             ".mp3",
+	"audio/wave":             ".wav",
+	"font/otf":               ".otf",
+	"font/ttf":               ".ttf",
+	"font/woff":              ".woff",
+	"font/woff2":             ".woff2",
+	"image/avif":             ".avif",
+	"image/bmp":              ".bmp",
+	"image/gif":              ".gif",
+	"image/jpeg":             ".jpg",
+	"image/png":              ".png",
+	"image/webp":             ".webp",
+	"image/x-icon":           ".ico",
+	"text/html":              ".html",
+	"text/plain":             ".txt",
+	"text/xml":               ".xml",
+	"video/avi":              ".avi",
+	"video/mp4":              ".mp4",
+	"video/webm":             ".webm",
+}
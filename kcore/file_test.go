@@ -0,0 +1,134 @@
+package kcore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var pdfUploadConfig = FileUploadConfig{MaxSize: 1024, AllowedTypes: []string{"application/pdf", "image/*"}}
+
+func chdirFiles(t *testing.T) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.MkdirAll("media/files", 0o700))
+}
+
+func assertNoTemporaryFile(t *testing.T) {
+	t.Helper()
+	entries, err := os.ReadDir("media/files")
+	require.NoError(t, err)
+	for _, entry := range entries {
+		assert.False(t, strings.HasPrefix(entry.Name(), ".upload-"), "temporary file left behind: %s", entry.Name())
+	}
+}
+
+func TestUploadFile(t *testing.T) {
+	chdirFiles(t)
+
+	metadata, err := UploadFile(strings.NewReader("%PDF-1.4 content"), "../invoice.PDF", pdfUploadConfig)
+
+	require.NoError(t, err)
+	assert.Equal(t, "invoice.PDF", metadata.OriginalName)
+	assert.Equal(t, int64(16), metadata.Size)
+	assert.Equal(t, "application/pdf", metadata.ContentType)
+	assert.Equal(t, fmt.Sprintf("%x", sha256.Sum256([]byte("%PDF-1.4 content"))), metadata.SHA256)
+	assert.True(t, strings.HasSuffix(string(metadata.File), ".pdf"))
+	content, err := os.ReadFile(metadata.File.Path())
+	require.NoError(t, err)
+	assert.Equal(t, "%PDF-1.4 content", string(content))
+	assertNoTemporaryFile(t)
+}
+
+func TestUploadFile_modeAndExtension(t *testing.T) {
+	chdirFiles(t)
+
+	metadata, err := UploadFile(bytes.NewReader([]byte("\xff\xd8\xff\xe0 jpeg")), "photo.JPEG", pdfUploadConfig)
+
+	require.NoError(t, err)
+	assert.Equal(t, "image/jpeg", metadata.ContentType)
+	assert.True(t, strings.HasSuffix(string(metadata.File), ".jpg"), "extensions don't depend on the mime tables of the host")
+	info, err := os.Stat(metadata.File.Path())
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o644), info.Mode().Perm(), "uploads are readable by a separate web server")
+}
+
+func TestUploadFile_notAllowed(t *testing.T) {
+	chdirFiles(t)
+
+	_, err := UploadFile(strings.NewReader("<html><script></script></html>"), "invoice.pdf", pdfUploadConfig)
+
+	assert.ErrorIs(t, err, ErrFileTypeNotAllowed)
+	assertNoTemporaryFile(t)
+}
+
+func TestUploadFile_tooLarge(t *testing.T) {
+	chdirFiles(t)
+
+	_, err := UploadFile(strings.NewReader("%PDF-1.4 "+strings.Repeat("a", 1024)), "invoice.pdf", pdfUploadConfig)
+
+	assert.ErrorIs(t, err, ErrFileTooLarge)
+	assertNoTemporaryFile(t)
+}
+
+func TestUploadFile_noMaxSize(t *testing.T) {
+	chdirFiles(t)
+	config := pdfUploadConfig
+	config.MaxSize = 0
+
+	metadata, err := UploadFile(strings.NewReader("%PDF-1.4 "+strings.Repeat("a", 2048)), "invoice.pdf", config)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(2057), metadata.Size)
+}
+
+func TestUploadFile_failedCopy(t *testing.T) {
+	chdirFiles(t)
+	errBroken := errors.New("broken connection")
+	raw := io.MultiReader(strings.NewReader("%PDF-1.4 "+strings.Repeat("a", 600)), iotest.ErrReader(errBroken))
+
+	_, err := UploadFile(raw, "invoice.pdf", pdfUploadConfig)
+
+	assert.ErrorIs(t, err, errBroken)
+	assertNoTemporaryFile(t)
+}
+
+func TestUploadFile_deduplicate(t *testing.T) {
+	chdirFiles(t)
+	config := pdfUploadConfig
+	config.Deduplicate = true
+
+	first, err := UploadFile(strings.NewReader("%PDF-1.4 content"), "a.pdf", config)
+	require.NoError(t, err)
+	second, err := UploadFile(strings.NewReader("%PDF-1.4 content"), "b.pdf", config)
+	require.NoError(t, err)
+
+	assert.Equal(t, first.File, second.File)
+	assert.Equal(t, "b.pdf", second.OriginalName)
+	assertNoTemporaryFile(t)
+
+	assert.True(t, first.File.Deduplicated())
+	assert.ErrorIs(t, first.File.Delete(), ErrSharedFile)
+	_, err = os.Stat(second.File.Path())
+	assert.NoError(t, err, "deduplicated files are never deleted by Delete")
+}
+
+func TestFile_Delete(t *testing.T) {
+	chdirFiles(t)
+	metadata, err := UploadFile(strings.NewReader("%PDF-1.4 content"), "a.pdf", pdfUploadConfig)
+	require.NoError(t, err)
+
+	assert.False(t, metadata.File.Deduplicated())
+	require.NoError(t, metadata.File.Delete())
+	_, err = os.Stat(metadata.File.Path())
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,393 @@
+package kcore
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Maximum memory used to parse multipart forms, the rest is stored in temporary files
+const maxFormMemory = 32 << 20
+
+var (
+	ErrInvalidForm = errors.New("invalid form")
+	// ErrUnsupportedField is a field type or a validation rule that DecodeForm can't handle, a programming error
+	ErrUnsupportedField = errors.New("unsupported form field")
+)
+
+// A FieldError describes why a form field is invalid.
+// Message is an English format string that can be used as a ki18n key: ki18n.Tr(ctx, err.Message, err.Args...)
+type FieldError struct {
+	Field   string
+	Message string
+	Args    []any
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + fmt.Sprintf(e.Message, e.Args...)
+}
+
+// FormErrors holds the first error of each invalid field, by form field name
+type FormErrors map[string]FieldError
+
+func (errs FormErrors) Error() string {
+	fields := make([]string, 0, len(errs))
+	for field := range errs {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	messages := make([]string, len(fields))
+	for i, field := range fields {
+		messages[i] = errs[field].Error()
+	}
+	return strings.Join(messages, ", ")
+}
+
+func (errs FormErrors) Is(target error) bool {
+	return target == ErrInvalidForm
+}
+
+// Has reports whether the field is invalid
+func (errs FormErrors) Has(field string) bool {
+	_, ok := errs[field]
+	return ok
+}
+
+func (errs FormErrors) add(field, message string, args ...any) {
+	if _, ok := errs[field]; !ok {
+		errs[field] = FieldError{Field: field, Message: message, Args: args}
+	}
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	fileHeaderType = reflect.TypeOf(&multipart.FileHeader{})
+)
+
+// Layouts accepted for time fields, matching the HTML date and time inputs
+var timeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", time.DateOnly, "15:04"}
+
+// BindForm parses the request form (urlencoded or multipart) and decodes it into dst.
+// The returned error is a FormErrors when the form content is invalid, see DecodeForm for other errors.
+func BindForm(r *http.Request, dst any) error {
+	var files map[string][]*multipart.FileHeader
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(maxFormMemory); err != nil {
+			return Wrap(err, "error parsing multipart form")
+		}
+		files = r.MultipartForm.File
+	} else if err := r.ParseForm(); err != nil {
+		return Wrap(err, "error parsing form")
+	}
+	return DecodeForm(r.Form, files, dst)
+}
+
+// DecodeForm decodes values and files into dst, which must be a pointer to a struct.
+//
+// Fields are matched with the `form:"name"` tag, or the field name. They are validated with the `validate` tag,
+// a comma separated list of rules: required, min=N, max=N (length for strings and slices, value for numbers),
+// email and regex=PATTERN (must be the last rule as the pattern can contain commas).
+// Pointer fields are set only when the form has a value. Fields of unsupported types, and rules that can't apply to
+// their field, return ErrUnsupportedField.
+func DecodeForm(values url.Values, files map[string][]*multipart.FileHeader, dst any) error {
+	value := reflect.ValueOf(dst)
+	Assert(value.Kind() == reflect.Pointer && value.Elem().Kind() == reflect.Struct, "form destination must be a pointer to a struct")
+	value = value.Elem()
+
+	errs := FormErrors{}
+	for i := range value.NumField() {
+		field := value.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := field.Tag.Get("form")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if err := decodeField(value.Field(i), name, values[name], files[name], errs); err != nil {
+			return Wrap(err, "error decoding form field "+name)
+		}
+		if !errs.Has(name) {
+			present := len(nonEmpty(values[name])) > 0 || len(files[name]) > 0
+			if err := validateField(value.Field(i), name, field.Tag.Get("validate"), present, errs); err != nil {
+				return Wrap(err, "error validating form field "+name)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// decodeField adds invalid values to errs, and only returns ErrUnsupportedField
+func decodeField(field reflect.Value, name string, raw []string, files []*multipart.FileHeader, errs FormErrors) error {
+	switch {
+	case field.Type() == fileHeaderType:
+		if len(files) > 0 {
+			field.Set(reflect.ValueOf(files[0]))
+		}
+	case field.Kind() == reflect.Slice && field.Type().Elem() == fileHeaderType:
+		field.Set(reflect.ValueOf(files))
+	case field.Kind() == reflect.Slice:
+		raw = nonEmpty(raw)
+		slice := reflect.MakeSlice(field.Type(), len(raw), len(raw))
+		for i, item := range raw {
+			if err := decodeValue(slice.Index(i), item); errors.Is(err, ErrUnsupportedField) {
+				return err
+			} else if err != nil {
+				errs.add(name, "Invalid value")
+				return nil
+			}
+		}
+		field.Set(slice)
+	default:
+		if len(raw) == 0 || raw[0] == "" {
+			return nil
+		}
+		if err := decodeValue(field, raw[0]); errors.Is(err, ErrUnsupportedField) {
+			return err
+		} else if err != nil {
+			errs.add(name, "Invalid value")
+		}
+	}
+	return nil
+}
+
+func nonEmpty(raw []string) []string {
+	result := make([]string, 0, len(raw))
+	for _, item := range raw {
+		if item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+func decodeValue(value reflect.Value, raw string) error {
+	if value.Kind() == reflect.Pointer {
+		pointer := reflect.New(value.Type().Elem())
+		if err := decodeValue(pointer.Elem(), raw); err != nil {
+			return err
+		}
+		value.Set(pointer)
+		return nil
+	}
+	if value.Type() == timeType {
+		for _, layout := range timeLayouts {
+			if t, err := time.Parse(layout, raw); err == nil {
+				value.Set(reflect.ValueOf(t))
+				return nil
+			}
+		}
+		return ErrInvalidForm
+	}
+	// Covers ID and TypedID
+	if unmarshaler, ok := value.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(raw))
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Bool:
+		// Checkboxes send "on" when checked
+		b, err := strconv.ParseBool(raw)
+		if err != nil && raw != "on" {
+			return err
+		}
+		value.SetBool(b || raw == "on")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(raw, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetFloat(n)
+	default:
+		return fmt.Errorf("%w: type %s", ErrUnsupportedField, value.Type())
+	}
+	return nil
+}
+
+func splitRules(tag string) []string {
+	rules := []string{}
+	for tag != "" {
+		if strings.HasPrefix(tag, "regex=") {
+			return append(rules, tag)
+		}
+		rule, rest, _ := strings.Cut(tag, ",")
+		rules = append(rules, strings.TrimSpace(rule))
+		tag = rest
+	}
+	return rules
+}
+
+// A fieldRule is a parsed validation rule, with the compiled pattern of regex rules
+type fieldRule struct {
+	name    string
+	param   string
+	pattern *regexp.Regexp
+}
+
+type rulesKey struct {
+	fieldType reflect.Type
+	tag       string
+}
+
+// Parsed rules by field type and validate tag, so that patterns are compiled once per field
+var rulesCache sync.Map
+
+// parseRules checks the rules of a validate tag, and returns ErrUnsupportedField for rules that can never apply to
+// the field type
+func parseRules(fieldType reflect.Type, tag string) ([]fieldRule, error) {
+	key := rulesKey{fieldType: fieldType, tag: tag}
+	if rules, ok := rulesCache.Load(key); ok {
+		return rules.([]fieldRule), nil //nolint:forcetypeassert
+	}
+	rules := []fieldRule{}
+	for _, rule := range splitRules(tag) {
+		name, param, _ := strings.Cut(rule, "=")
+		parsed := fieldRule{name: name, param: param}
+		switch name {
+		case "required":
+		case "min", "max":
+			if _, err := strconv.ParseFloat(param, 64); err != nil || !hasBound(fieldType) {
+				return nil, fmt.Errorf("%w: %s rule %q not supported on %s", ErrUnsupportedField, name, param, fieldType)
+			}
+		case "email", "regex":
+			if fieldType.Kind() != reflect.String {
+				return nil, fmt.Errorf("%w: %s rule not supported on %s", ErrUnsupportedField, name, fieldType)
+			}
+			if name == "regex" {
+				pattern, err := regexp.Compile(param)
+				if err != nil {
+					return nil, fmt.Errorf("%w: invalid regex rule %q: %w", ErrUnsupportedField, param, err)
+				}
+				parsed.pattern = pattern
+			}
+		default:
+			return nil, fmt.Errorf("%w: unknown validation rule %s", ErrUnsupportedField, name)
+		}
+		rules = append(rules, parsed)
+	}
+	rulesCache.Store(key, rules)
+	return rules, nil
+}
+
+// hasBound reports whether min and max rules apply to the type: a length for strings and slices, or a number
+func hasBound(fieldType reflect.Type) bool {
+	switch fieldType.Kind() {
+	case reflect.String, reflect.Slice, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// Rules other than required only apply to fields present in the form, the rules of pointer fields to their value
+func validateField(field reflect.Value, name string, tag string, present bool, errs FormErrors) error {
+	fieldType := field.Type()
+	if fieldType.Kind() == reflect.Pointer && fieldType != fileHeaderType {
+		fieldType = fieldType.Elem()
+		if field.IsNil() {
+			present = false
+		} else {
+			field = field.Elem()
+		}
+	}
+	rules, err := parseRules(fieldType, tag)
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		if errs.Has(name) {
+			return nil
+		}
+		if rule.name != "required" && !present {
+			continue
+		}
+		switch rule.name {
+		case "required":
+			if !present {
+				errs.add(name, "This field is required")
+			}
+		case "min", "max":
+			validateBound(field, name, rule.name, rule.param, errs)
+		case "email":
+			if s := field.String(); s != "" {
+				if address, err := mail.ParseAddress(s); err != nil || address.Address != s {
+					errs.add(name, "Must be a valid email address")
+				}
+			}
+		case "regex":
+			if s := field.String(); s != "" && !rule.pattern.MatchString(s) {
+				errs.add(name, "Invalid format")
+			}
+		}
+	}
+	return nil
+}
+
+// validateBound applies a min or max rule, checked by parseRules
+func validateBound(field reflect.Value, name string, rule string, param string, errs FormErrors) {
+	bound, err := strconv.ParseFloat(param, 64)
+	Expect(err, "error parsing validation bound")
+
+	var actual float64
+	unit := ""
+	switch field.Kind() {
+	case reflect.String:
+		if field.Len() == 0 {
+			return
+		}
+		actual = float64(len([]rune(field.String())))
+		unit = " characters"
+	case reflect.Slice:
+		if field.Len() == 0 {
+			return
+		}
+		actual = float64(field.Len())
+		unit = " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		actual = float64(field.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		actual = float64(field.Uint())
+	case reflect.Float32, reflect.Float64:
+		actual = field.Float()
+	default:
+		Assert(false, "bound rules are checked by parseRules")
+	}
+
+	switch {
+	case rule == "min" && actual < bound:
+		errs.add(name, "Must be at least %v"+unit, bound)
+	case rule == "max" && actual > bound:
+		errs.add(name, "Must be at most %v"+unit, bound)
+	}
+}
@@ -0,0 +1,207 @@
+package kcore
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type signupForm struct {
+	Name     string    `form:"name" validate:"required,min=3,max=10"`
+	Email    string    `form:"email" validate:"required,email"`
+	Age      int       `form:"age" validate:"min=18"`
+	Team     ID        `form:"team"`
+	Birthday time.Time `form:"birthday"`
+	Tags     []string  `form:"tags" validate:"max=2"`
+	Code     string    `form:"code" validate:"regex=^[a-z]{2,3}$"`
+	Accept   bool      `form:"accept"`
+	internal string
+}
+
+func TestDecodeForm_valid(t *testing.T) {
+	team := NewID()
+	values := url.Values{
+		"name":     {"John"},
+		"email":    {"john@example.com"},
+		"age":      {"42"},
+		"team":     {team.String()},
+		"birthday": {"1984-02-01"},
+		"tags":     {"a", "b"},
+		"code":     {"fr"},
+		"accept":   {"on"},
+	}
+
+	var form signupForm
+	err := DecodeForm(values, nil, &form)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "John", form.Name)
+	assert.Equal(t, 42, form.Age)
+	assert.Equal(t, team, form.Team)
+	assert.Equal(t, time.Date(1984, time.February, 1, 0, 0, 0, 0, time.UTC), form.Birthday)
+	assert.Equal(t, []string{"a", "b"}, form.Tags)
+	assert.True(t, form.Accept)
+}
+
+func TestDecodeForm_invalid(t *testing.T) {
+	values := url.Values{
+		"name":  {"Jo"},
+		"email": {"not an email"},
+		"age":   {"ten"},
+		"team":  {"???"},
+		"tags":  {"a", "b", "c"},
+		"code":  {"FRA"},
+	}
+
+	var form signupForm
+	err := DecodeForm(values, nil, &form)
+
+	assert.ErrorIs(t, err, ErrInvalidForm)
+	errs, ok := err.(FormErrors)
+	require.True(t, ok)
+	assert.Equal(t, FieldError{Field: "name", Message: "Must be at least %v characters", Args: []any{3.0}}, errs["name"])
+	assert.Equal(t, "Must be a valid email address", errs["email"].Message)
+	assert.Equal(t, "Invalid value", errs["age"].Message)
+	assert.Equal(t, "Invalid value", errs["team"].Message)
+	assert.Equal(t, "Must be at most %v items", errs["tags"].Message)
+	assert.Equal(t, "Invalid format", errs["code"].Message)
+	assert.False(t, errs.Has("birthday"))
+}
+
+func TestDecodeForm_required(t *testing.T) {
+	var form signupForm
+	err := DecodeForm(url.Values{}, nil, &form)
+
+	errs, ok := err.(FormErrors)
+	require.True(t, ok)
+	assert.Len(t, errs, 2)
+	assert.Equal(t, "This field is required", errs["name"].Message)
+	assert.Equal(t, "This field is required", errs["email"].Message)
+}
+
+func TestBindForm_multipart(t *testing.T) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	require.NoError(t, writer.WriteField("title", "Holidays"))
+	part, err := writer.CreateFormFile("picture", "beach.png")
+	require.NoError(t, err)
+	_, err = part.Write([]byte("content"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	r := httptest.NewRequest(http.MethodPost, "/", &body)
+	r.Header.Set("Content-Type", writer.FormDataContentType())
+
+	var form struct {
+		Title   string                `form:"title"`
+		Picture *multipart.FileHeader `form:"picture" validate:"required"`
+	}
+	err = BindForm(r, &form)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Holidays", form.Title)
+	assert.Equal(t, "beach.png", form.Picture.Filename)
+}
+
+func TestBindForm_urlencoded(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("title=Holidays"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var form struct {
+		Title   string                `form:"title"`
+		Picture *multipart.FileHeader `form:"picture" validate:"required"`
+	}
+	err := BindForm(r, &form)
+
+	errs, ok := err.(FormErrors)
+	require.True(t, ok)
+	assert.Equal(t, "Holidays", form.Title)
+	assert.True(t, errs.Has("picture"))
+}
+
+func TestDecodeForm_invalidRules(t *testing.T) {
+	var pattern struct {
+		Code string `form:"code" validate:"regex=^[a-z"`
+	}
+	var email struct {
+		Age int `form:"age" validate:"email"`
+	}
+
+	err := DecodeForm(url.Values{"code": {"fr"}}, nil, &pattern)
+	assert.EqualError(t, err, "error validating form field code: unsupported form field: invalid regex rule \"^[a-z\": error parsing regexp: missing closing ]: `[a-z`")
+	err = DecodeForm(url.Values{"age": {"42"}}, nil, &email)
+	assert.EqualError(t, err, "error validating form field age: unsupported form field: email rule not supported on int")
+	assert.NotErrorIs(t, err, ErrInvalidForm)
+}
+
+func TestDecodeForm_pointers(t *testing.T) {
+	var form struct {
+		Age      *int       `form:"age" validate:"min=18"`
+		Nickname *string    `form:"nickname" validate:"max=5"`
+		Team     *ID        `form:"team" validate:"required"`
+		Birthday *time.Time `form:"birthday"`
+		Guests   []*int     `form:"guests"`
+	}
+	team := NewID()
+
+	err := DecodeForm(url.Values{"age": {"42"}, "team": {team.String()}, "birthday": {"1984-02-01"}, "guests": {"1", "2"}}, nil, &form)
+
+	require.NoError(t, err)
+	require.NotNil(t, form.Age)
+	assert.Equal(t, 42, *form.Age)
+	assert.Nil(t, form.Nickname, "missing values leave pointers nil")
+	assert.Equal(t, team, *form.Team)
+	assert.Equal(t, time.Date(1984, time.February, 1, 0, 0, 0, 0, time.UTC), *form.Birthday)
+	require.Len(t, form.Guests, 2)
+	assert.Equal(t, 2, *form.Guests[1])
+
+	err = DecodeForm(url.Values{"age": {"12"}, "nickname": {"Johnny"}, "team": {"???"}}, nil, &form)
+	errs, ok := err.(FormErrors)
+	require.True(t, ok)
+	assert.Equal(t, "Must be at least %v", errs["age"].Message)
+	assert.Equal(t, "Must be at most %v characters", errs["nickname"].Message)
+	assert.Equal(t, "Invalid value", errs["team"].Message)
+}
+
+func TestDecodeForm_unsupportedFields(t *testing.T) {
+	var address struct {
+		Address struct{ City string } `form:"address"`
+	}
+	var addresses struct {
+		Addresses []struct{ City string } `form:"addresses"`
+	}
+	var since struct {
+		Since time.Time `form:"since" validate:"min=2000"`
+	}
+	var team struct {
+		Team ID `form:"team" validate:"max=1"`
+	}
+
+	assert.ErrorIs(t, DecodeForm(url.Values{"address": {"Paris"}}, nil, &address), ErrUnsupportedField)
+	assert.ErrorIs(t, DecodeForm(url.Values{"addresses": {"Paris"}}, nil, &addresses), ErrUnsupportedField)
+	assert.ErrorIs(t, DecodeForm(url.Values{"since": {"2026-05-12"}}, nil, &since), ErrUnsupportedField)
+	err := DecodeForm(url.Values{"team": {NewID().String()}}, nil, &team)
+	assert.ErrorIs(t, err, ErrUnsupportedField)
+	assert.ErrorContains(t, err, "error validating form field team")
+}
+
+func TestParseRules_cached(t *testing.T) {
+	fieldType := reflect.TypeFor[string]()
+
+	first, err := parseRules(fieldType, "required,regex=^[a-z]{2,3}$")
+	require.NoError(t, err)
+	second, err := parseRules(fieldType, "required,regex=^[a-z]{2,3}$")
+	require.NoError(t, err)
+
+	require.Len(t, first, 2)
+	assert.Equal(t, "^[a-z]{2,3}$", first[1].param)
+	assert.Same(t, first[1].pattern, second[1].pattern, "patterns are compiled once")
+}
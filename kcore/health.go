@@ -0,0 +1,201 @@
+package kcore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+var startedAt = time.Now()
+
+// BuildInfo describes the running binary, from runtime/debug.ReadBuildInfo
+type BuildInfo struct {
+	Module    string    `json:"module"`
+	Version   string    `json:"version"`
+	Revision  string    `json:"revision"`
+	Dirty     bool      `json:"dirty"`
+	GoVersion string    `json:"goVersion"`
+	StartedAt time.Time `json:"startedAt"`
+}
+
+func ReadBuildInfo() BuildInfo {
+	info := BuildInfo{StartedAt: startedAt}
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	info.Module, info.Version, info.GoVersion = build.Main.Path, build.Main.Version, build.GoVersion
+	for _, setting := range build.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			info.Revision = setting.Value
+		case "vcs.modified":
+			info.Dirty = setting.Value == "true"
+		}
+	}
+	return info
+}
+
+// AttachBuildInfo adds the version, revision and startup time to every record of the default logger
+func AttachBuildInfo(info BuildInfo) {
+	slog.SetDefault(slog.Default().With(
+		slog.String("version", info.Version),
+		slog.String("revision", info.Revision),
+		slog.Time("startedAt", info.StartedAt),
+	))
+}
+
+// A HealthCheck reports an error when a dependency, such as the database, is not usable
+type HealthCheck func(ctx context.Context) error
+
+type healthCheck struct {
+	name    string
+	check   HealthCheck
+	timeout time.Duration
+}
+
+// HealthChecks runs named checks concurrently, each with its own timeout
+type HealthChecks struct {
+	// Timeout of checks added without one
+	Timeout time.Duration
+	// ShowErrors adds the error of failing checks to the served report, which may leak internal details on public endpoints
+	ShowErrors bool
+
+	mutex  sync.Mutex
+	checks []healthCheck
+}
+
+func NewHealthChecks() *HealthChecks {
+	return &HealthChecks{Timeout: 2 * time.Second}
+}
+
+// Add registers a check, a zero timeout uses the default Timeout
+func (h *HealthChecks) Add(name string, check HealthCheck, timeout time.Duration) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.checks = append(h.checks, healthCheck{name: name, check: check, timeout: timeout})
+}
+
+type HealthCheckResult struct {
+	Healthy  bool          `json:"healthy"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+type HealthReport struct {
+	Healthy bool                         `json:"healthy"`
+	Build   BuildInfo                    `json:"build"`
+	Uptime  time.Duration                `json:"uptime"`
+	Checks  map[string]HealthCheckResult `json:"checks"`
+}
+
+// Run runs all checks and reports the first error of each
+func (h *HealthChecks) Run(ctx context.Context) HealthReport {
+	h.mutex.Lock()
+	checks := h.checks
+	h.mutex.Unlock()
+
+	report := HealthReport{Healthy: true, Build: ReadBuildInfo(), Uptime: time.Since(startedAt), Checks: map[string]HealthCheckResult{}}
+	results := make([]HealthCheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = h.run(ctx, check)
+		}()
+	}
+	wg.Wait()
+	for i, check := range checks {
+		report.Checks[check.name] = results[i]
+		report.Healthy = report.Healthy && results[i].Healthy
+	}
+	return report
+}
+
+func (h *HealthChecks) run(ctx context.Context, check healthCheck) HealthCheckResult {
+	timeout := check.timeout
+	if timeout == 0 {
+		timeout = h.Timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- check.check(ctx) }()
+	// Checks ignoring their context must not block the report
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	result := HealthCheckResult{Healthy: err == nil, Duration: time.Since(start)}
+	if err != nil {
+		result.Error = err.Error()
+		slog.Warn("health check failed", slog.String("check", check.name), slog.String("error", err.Error()))
+	}
+	return result
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	content, err := json.MarshalIndent(value, "", "  ")
+	Expect(err, "error marshalling JSON response")
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_, err = w.Write(content)
+	Expect(err, "error writing JSON response")
+}
+
+// Handler serves the JSON report, with a 503 status when a check fails, and the check errors only with ShowErrors
+func (h *HealthChecks) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := h.Run(r.Context())
+		status := http.StatusOK
+		if !report.Healthy {
+			status = http.StatusServiceUnavailable
+		}
+		if !h.ShowErrors {
+			for name, result := range report.Checks {
+				result.Error = ""
+				report.Checks[name] = result
+			}
+		}
+		writeJSON(w, status, report)
+	})
+}
+
+// VersionHandler serves the build info as JSON
+func VersionHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, ReadBuildInfo())
+	})
+}
+
+// DBCheck pings the database, also used for SQL event stores and job queues
+func DBCheck(db *sql.DB) HealthCheck {
+	return db.PingContext
+}
+
+// StorageCheck writes and removes a file in dir, such as the media directory
+func StorageCheck(dir string) HealthCheck {
+	return func(ctx context.Context) error {
+		file, err := os.CreateTemp(dir, ".healthcheck-*")
+		if err != nil {
+			return Wrap(err, "error creating file")
+		}
+		file.Close() //nolint:errcheck,gosec
+		if err := os.Remove(file.Name()); err != nil {
+			return Wrap(err, "error removing file")
+		}
+		return nil
+	}
+}
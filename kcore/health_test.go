@@ -0,0 +1,98 @@
+package kcore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+)
+
+func TestReadBuildInfo(t *testing.T) {
+	info := ReadBuildInfo()
+
+	assert.NotEmpty(t, info.GoVersion)
+	assert.Equal(t, startedAt, info.StartedAt)
+}
+
+func TestAttachBuildInfo(t *testing.T) {
+	var output strings.Builder
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&output, nil)))
+	defer slog.SetDefault(defaultLogger)
+
+	AttachBuildInfo(BuildInfo{Version: "v1.2.3", Revision: "abc123", StartedAt: time.Date(2026, time.May, 12, 10, 0, 0, 0, time.UTC)})
+	slog.Info("started")
+
+	assert.Contains(t, output.String(), `msg=started version=v1.2.3 revision=abc123 startedAt=2026-05-12T10:00:00.000Z`)
+}
+
+func TestHealthChecks_Handler(t *testing.T) {
+	checks := NewHealthChecks()
+	checks.ShowErrors = true
+	checks.Add("database", func(ctx context.Context) error { return nil }, 0)
+	checks.Add("queue", func(ctx context.Context) error { return errors.New("backlog too large") }, 0)
+	checks.Add("storage", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, time.Millisecond)
+	checks.Add("stuck", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}, time.Millisecond)
+	w := httptest.NewRecorder()
+
+	checks.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var report HealthReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.False(t, report.Healthy)
+	assert.True(t, report.Checks["database"].Healthy)
+	assert.Equal(t, "backlog too large", report.Checks["queue"].Error)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["storage"].Error)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["stuck"].Error)
+	assert.NotEmpty(t, report.Build.GoVersion)
+}
+
+func TestHealthChecks_HandlerHidesErrors(t *testing.T) {
+	checks := NewHealthChecks()
+	checks.Add("database", func(ctx context.Context) error { return errors.New("dial tcp 10.0.0.5:5432: connection refused") }, 0)
+	w := httptest.NewRecorder()
+
+	checks.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.NotContains(t, w.Body.String(), "10.0.0.5")
+	var report HealthReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.False(t, report.Checks["database"].Healthy)
+}
+
+func TestVersionHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	VersionHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/version", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var info BuildInfo
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &info))
+	expected := ReadBuildInfo()
+	assert.Equal(t, expected.GoVersion, info.GoVersion)
+	assert.True(t, expected.StartedAt.Equal(info.StartedAt))
+}
+
+func TestStorageCheck(t *testing.T) {
+	dir := t.TempDir()
+
+	require.NoError(t, StorageCheck(dir)(context.Background()))
+	assert.Error(t, StorageCheck(dir+"/missing")(context.Background()))
+}
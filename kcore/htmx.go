@@ -0,0 +1,97 @@
+package kcore
+
+import (
+	"bytes"
+	"context"
+	"html"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/a-h/templ"
+)
+
+// IsHTMX reports whether the request was issued by htmx
+func IsHTMX(r *http.Request) bool {
+	return r.Header.Get("HX-Request") == "true"
+}
+
+// IsBoosted reports whether the request comes from an hx-boost link or form
+func IsBoosted(r *http.Request) bool {
+	return r.Header.Get("HX-Boosted") == "true"
+}
+
+// HTMXTarget returns the id of the element targeted by the htmx request, if any
+func HTMXTarget(r *http.Request) string {
+	return r.Header.Get("HX-Target")
+}
+
+// IsPartial reports whether the response should only contain a fragment.
+// Boosted requests swap the whole body, so they expect the full layout.
+func IsPartial(r *http.Request) bool {
+	return IsHTMX(r) && !IsBoosted(r)
+}
+
+// RenderFragment renders only the fragment for partial htmx requests, and the fragment wrapped in its layout otherwise
+func RenderFragment(w http.ResponseWriter, r *http.Request, fragment templ.Component, layout func(templ.Component) templ.Component) {
+	w.Header().Add("Vary", "HX-Request")
+	if IsPartial(r) || layout == nil {
+		RenderPage(r.Context(), fragment, w)
+		return
+	}
+	RenderPage(r.Context(), layout(fragment), w)
+}
+
+// RenderOOB renders the main component followed by out-of-band components, swapped by htmx in a single response
+func RenderOOB(ctx context.Context, w http.ResponseWriter, main templ.Component, oob ...templ.Component) {
+	components := append([]templ.Component{main}, oob...)
+	RenderPage(ctx, templ.Join(components...), w)
+}
+
+// OOBSwap wraps a component in an element that htmx swaps out-of-band in place of the element with the same id
+func OOBSwap(id string, swap string, component templ.Component) templ.Component {
+	if swap == "" {
+		swap = "true"
+	}
+	return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+		var buf bytes.Buffer
+		buf.WriteString(`<div id="` + html.EscapeString(id) + `" hx-swap-oob="` + html.EscapeString(swap) + `">`)
+		if err := component.Render(ctx, &buf); err != nil {
+			return Wrap(err, "error rendering oob component")
+		}
+		buf.WriteString(`</div>`)
+		_, err := buf.WriteTo(w)
+		return err
+	})
+}
+
+// HTMXRedirect asks htmx to do a full page redirect to url
+func HTMXRedirect(w http.ResponseWriter, url string) {
+	w.Header().Set("HX-Redirect", url)
+}
+
+// HTMXTrigger asks htmx to trigger the given client side events
+func HTMXTrigger(w http.ResponseWriter, events ...string) {
+	w.Header().Set("HX-Trigger", strings.Join(events, ", "))
+}
+
+// HTMXRetarget changes the element the response is swapped into
+func HTMXRetarget(w http.ResponseWriter, selector string) {
+	w.Header().Set("HX-Retarget", selector)
+}
+
+// HTMXPushURL pushes url in the browser history
+func HTMXPushURL(w http.ResponseWriter, url string) {
+	w.Header().Set("HX-Push-Url", url)
+}
+
+// Redirect works for both htmx and plain browser requests.
+// htmx follows 3xx transparently inside the XHR, so it needs HX-Redirect with a 200 instead.
+func Redirect(w http.ResponseWriter, r *http.Request, url string, code int) {
+	if IsHTMX(r) {
+		HTMXRedirect(w, url)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	http.Redirect(w, r, url, code)
+}
@@ -0,0 +1,97 @@
+package kcore
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/a-h/templ"
+	"github.com/stretchr/testify/assert"
+)
+
+func text(s string) templ.Component {
+	return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+		_, err := io.WriteString(w, s)
+		return err
+	})
+}
+
+func layout(c templ.Component) templ.Component {
+	return templ.Join(text("<html>"), c, text("</html>"))
+}
+
+func TestRenderFragment_plainRequest(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	rr := httptest.NewRecorder()
+
+	RenderFragment(rr, r, text("content"), layout)
+
+	assert.Equal(t, "<html>content</html>", rr.Body.String())
+	assert.Equal(t, "HX-Request", rr.Header().Get("Vary"))
+}
+
+func TestRenderFragment_htmxRequest(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("HX-Request", "true")
+	rr := httptest.NewRecorder()
+
+	RenderFragment(rr, r, text("content"), layout)
+
+	assert.Equal(t, "content", rr.Body.String())
+}
+
+func TestRenderFragment_boostedRequest(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("HX-Request", "true")
+	r.Header.Set("HX-Boosted", "true")
+	rr := httptest.NewRecorder()
+
+	RenderFragment(rr, r, text("content"), layout)
+
+	assert.Equal(t, "<html>content</html>", rr.Body.String())
+}
+
+func TestRenderOOB(t *testing.T) {
+	rr := httptest.NewRecorder()
+
+	RenderOOB(context.Background(), rr, text("main"), OOBSwap("counter", "", text("3")), OOBSwap("toast", "beforeend", text("Saved")))
+
+	assert.Equal(t, `main<div id="counter" hx-swap-oob="true">3</div><div id="toast" hx-swap-oob="beforeend">Saved</div>`, rr.Body.String())
+}
+
+func TestRedirect_htmxRequest(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.Header.Set("HX-Request", "true")
+	rr := httptest.NewRecorder()
+
+	Redirect(rr, r, "/done", http.StatusSeeOther)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "/done", rr.Header().Get("HX-Redirect"))
+	assert.Empty(t, rr.Header().Get("Location"))
+}
+
+func TestRedirect_plainRequest(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	rr := httptest.NewRecorder()
+
+	Redirect(rr, r, "/done", http.StatusSeeOther)
+
+	assert.Equal(t, http.StatusSeeOther, rr.Code)
+	assert.Equal(t, "/done", rr.Header().Get("Location"))
+	assert.Empty(t, rr.Header().Get("HX-Redirect"))
+}
+
+func TestHTMXResponseHeaders(t *testing.T) {
+	rr := httptest.NewRecorder()
+
+	HTMXTrigger(rr, "saved", "refresh")
+	HTMXRetarget(rr, "#errors")
+	HTMXPushURL(rr, "/items/1")
+
+	assert.Equal(t, "saved, refresh", rr.Header().Get("HX-Trigger"))
+	assert.Equal(t, "#errors", rr.Header().Get("HX-Retarget"))
+	assert.Equal(t, "/items/1", rr.Header().Get("HX-Push-Url"))
+}
@@ -1,10 +1,19 @@
 package kcore
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
+	"net/http"
 	"os"
+	"path/filepath"
+	"slices"
+)
+
+var (
+	ErrNotAnImage    = errors.New("file is not a supported image")
+	ErrImageTooLarge = errors.New("image dimensions exceed limits")
 )
 
 type Image (ID)
@@ -13,18 +22,75 @@ func NewImage() Image {
 	return Image(NewID())
 }
 
+// Path names the file after the canonical UUID form, which existing images are stored under
 func (image Image) Path() string {
-	return fmt.Sprintf("media/images/%s", image)
+	return fmt.Sprintf("media/images/%s", image.UUID.String())
+}
+
+// VariantPath returns the path of a processed variant, stored next to the original.
+// An empty variant returns the original path.
+func (image Image) VariantPath(variant string) string {
+	if variant == "" {
+		return image.Path()
+	}
+	return fmt.Sprintf("%s_%s", image.Path(), variant)
 }
 
 func (image *Image) Save(raw multipart.File) error {
-	dest, err := os.Create(image.Path())
+	return atomicWrite(image.Path(), raw)
+}
+
+// ImageVariant is a resized copy of the original, fitting in MaxWidth x MaxHeight
+type ImageVariant struct {
+	Name      string
+	MaxWidth  int
+	MaxHeight int
+}
+
+// ImageConfig configures the checks and variants of the upload pipeline
+type ImageConfig struct {
+	// MaxSize of the upload in bytes, 0 for no limit
+	MaxSize   int64
+	MaxWidth  int
+	MaxHeight int
+	// MaxGIFPixels bounds width x height x frames of GIF animations, 0 for no limit
+	MaxGIFPixels int64
+	JPEGQuality  int
+	Variants     []ImageVariant
+}
+
+var DefaultImageConfig = ImageConfig{
+	MaxSize:      20 << 20,
+	MaxWidth:     8000,
+	MaxHeight:    8000,
+	MaxGIFPixels: 100_000_000,
+	JPEGQuality:  85,
+	Variants: []ImageVariant{
+		{Name: "thumbnail", MaxWidth: 200, MaxHeight: 200},
+		{Name: "medium", MaxWidth: 800, MaxHeight: 800},
+	},
+}
+
+func (config ImageConfig) hasVariant(name string) bool {
+	return name == "" || slices.ContainsFunc(config.Variants, func(variant ImageVariant) bool { return variant.Name == name })
+}
+
+// Process checks the uploaded image and stores it with its variants.
+// The content type is sniffed from the content, and JPEG and PNG images are re-encoded,
+// which strips EXIF metadata after the EXIF orientation has been applied.
+func (image *Image) Process(raw io.Reader, config ImageConfig) error {
+	upload, err := decodeUpload(raw, config)
 	if err != nil {
-		return Wrap(err, "error creating image destination")
+		return err
 	}
-	_, err = io.Copy(dest, raw)
-	if err != nil {
-		return Wrap(err, "error copying to image destination")
+	if err := writeImage(image.Path(), upload.img, upload.original); err != nil {
+		return err
+	}
+	for _, variant := range config.Variants {
+		resized := resizeToFit(upload.img, variant.MaxWidth, variant.MaxHeight)
+		if err := writeImage(image.VariantPath(variant.Name), resized, upload.variants); err != nil {
+			return Wrap(err, "error writing variant "+variant.Name)
+		}
 	}
 	return nil
 }
@@ -34,5 +100,42 @@ func (image *Image) Delete() error {
 	if err != nil {
 		return Wrap(err, "error deleting image")
 	}
+	variants, err := filepath.Glob(image.Path() + "_*")
+	Expect(err, "error matching image variants")
+	for _, variant := range variants {
+		if err := os.Remove(variant); err != nil {
+			return Wrap(err, "error deleting image variant")
+		}
+	}
 	return nil
 }
+
+// ImageHandler serves image variants from the {id} and {variant} path values, which are immutable
+//
+//	handler.Handle("GET /images/{id}/{variant}", kcore.ImageHandler(kcore.DefaultImageConfig))
+func ImageHandler(config ImageConfig) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := ParseID(r.PathValue("id"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		variant := r.PathValue("variant")
+		if variant == "original" {
+			variant = ""
+		}
+		if !config.hasVariant(variant) {
+			http.NotFound(w, r)
+			return
+		}
+		ServeImage(w, r, Image(id), variant)
+	})
+}
+
+// ServeImage writes the image variant, with long lived caching headers unless already set
+func ServeImage(w http.ResponseWriter, r *http.Request, image Image, variant string) {
+	if w.Header().Get("Cache-Control") == "" {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	}
+	serveMedia(w, r, image.VariantPath(variant))
+}
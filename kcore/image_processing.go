@@ -0,0 +1,262 @@
+package kcore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+)
+
+type imageEncoder func(w io.Writer, img image.Image) error
+
+// A decodedUpload is a checked upload, with the encoders of the original and of its variants
+type decodedUpload struct {
+	img      image.Image
+	original imageEncoder
+	variants imageEncoder
+}
+
+// decodeUpload sniffs, checks and decodes an uploaded image, with its EXIF orientation applied.
+// JPEG images are encoded back as JPEG, PNG images as PNG. GIF originals are kept untouched, as re-encoding would drop
+// the frames of animations, and their variants are PNG images of the first frame.
+func decodeUpload(raw io.Reader, config ImageConfig) (decodedUpload, error) {
+	if config.MaxSize > 0 {
+		raw = io.LimitReader(raw, config.MaxSize+1)
+	}
+	content, err := io.ReadAll(raw)
+	if err != nil {
+		return decodedUpload{}, Wrap(err, "error reading image")
+	}
+	if config.MaxSize > 0 && int64(len(content)) > config.MaxSize {
+		return decodedUpload{}, fmt.Errorf("%w: more than %d bytes", ErrFileTooLarge, config.MaxSize)
+	}
+	contentType := http.DetectContentType(content)
+	if contentType != "image/jpeg" && contentType != "image/png" && contentType != "image/gif" {
+		return decodedUpload{}, fmt.Errorf("%w: %s", ErrNotAnImage, contentType)
+	}
+
+	// Check dimensions before decoding the whole image, to avoid decompression bombs
+	imgConfig, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return decodedUpload{}, fmt.Errorf("%w: %w", ErrNotAnImage, err)
+	}
+	if imgConfig.Width > config.MaxWidth || imgConfig.Height > config.MaxHeight {
+		return decodedUpload{}, fmt.Errorf("%w: %dx%d", ErrImageTooLarge, imgConfig.Width, imgConfig.Height)
+	}
+
+	switch contentType {
+	case "image/gif":
+		// Count the frames before decoding them, as a small file can hold thousands of full canvas frames
+		frames, err := gifFrameCount(content)
+		if err != nil {
+			return decodedUpload{}, fmt.Errorf("%w: %w", ErrNotAnImage, err)
+		}
+		if pixels := int64(imgConfig.Width) * int64(imgConfig.Height) * int64(frames); config.MaxGIFPixels > 0 && pixels > config.MaxGIFPixels {
+			return decodedUpload{}, fmt.Errorf("%w: %d frames of %dx%d", ErrImageTooLarge, frames, imgConfig.Width, imgConfig.Height)
+		}
+		// Decoding every frame checks the whole animation before it is stored
+		animation, err := gif.DecodeAll(bytes.NewReader(content))
+		if err != nil {
+			return decodedUpload{}, fmt.Errorf("%w: %w", ErrNotAnImage, err)
+		}
+		return decodedUpload{
+			img: animation.Image[0],
+			original: func(w io.Writer, img image.Image) error {
+				_, err := w.Write(content)
+				return err
+			},
+			variants: png.Encode,
+		}, nil
+	case "image/png":
+		img, err := png.Decode(bytes.NewReader(content))
+		if err != nil {
+			return decodedUpload{}, fmt.Errorf("%w: %w", ErrNotAnImage, err)
+		}
+		return decodedUpload{img: img, original: png.Encode, variants: png.Encode}, nil
+	}
+	img, err := jpeg.Decode(bytes.NewReader(content))
+	if err != nil {
+		return decodedUpload{}, fmt.Errorf("%w: %w", ErrNotAnImage, err)
+	}
+	encode := func(w io.Writer, img image.Image) error {
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: config.JPEGQuality})
+	}
+	return decodedUpload{img: applyOrientation(img, exifOrientation(content)), original: encode, variants: encode}, nil
+}
+
+var errInvalidGIF = errors.New("invalid gif")
+
+// gifFrameCount walks the blocks of a GIF to count its frames, skipping their pixel data
+func gifFrameCount(content []byte) (int, error) {
+	const headerLen = 13
+	if len(content) < headerLen {
+		return 0, errInvalidGIF
+	}
+	pos := headerLen
+	if flags := content[10]; flags&0x80 != 0 {
+		pos += 3 << (flags&0x07 + 1) // global color table
+	}
+	// skipSubBlocks skips data sub-blocks, each prefixed by its size, up to the empty block
+	skipSubBlocks := func() bool {
+		for pos < len(content) {
+			size := int(content[pos])
+			pos += 1 + size
+			if size == 0 {
+				return true
+			}
+		}
+		return false
+	}
+	frames := 0
+	for pos < len(content) {
+		switch content[pos] {
+		case 0x21: // extension: introducer, label and sub-blocks
+			pos += 2
+			if !skipSubBlocks() {
+				return 0, errInvalidGIF
+			}
+		case 0x2C: // image descriptor, optional local color table, LZW code size and sub-blocks
+			if pos+10 > len(content) {
+				return 0, errInvalidGIF
+			}
+			if flags := content[pos+9]; flags&0x80 != 0 {
+				pos += 3 << (flags&0x07 + 1)
+			}
+			pos += 11
+			if !skipSubBlocks() {
+				return 0, errInvalidGIF
+			}
+			frames++
+		case 0x3B: // trailer
+			return frames, nil
+		default:
+			return 0, errInvalidGIF
+		}
+	}
+	return 0, errInvalidGIF
+}
+
+func writeImage(path string, img image.Image, encode imageEncoder) error {
+	var buf bytes.Buffer
+	if err := encode(&buf, img); err != nil {
+		return Wrap(err, "error encoding image")
+	}
+	return atomicWrite(path, &buf)
+}
+
+// exifOrientation reads the orientation tag from the EXIF segment of a JPEG, 1 (normal) if absent
+func exifOrientation(content []byte) int {
+	const orientationTag = 0x0112
+	offset := 2 // skip SOI
+	for offset+4 <= len(content) && content[offset] == 0xFF {
+		marker := content[offset+1]
+		length := int(binary.BigEndian.Uint16(content[offset+2:]))
+		if marker == 0xDA || offset+2+length > len(content) { // start of scan, no more metadata
+			return 1
+		}
+		segment := content[offset+4 : offset+2+length]
+		offset += 2 + length
+		if marker != 0xE1 || !bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			continue
+		}
+
+		tiff := segment[6:]
+		if len(tiff) < 8 {
+			return 1
+		}
+		var order binary.ByteOrder = binary.BigEndian
+		if string(tiff[:2]) == "II" {
+			order = binary.LittleEndian
+		}
+		ifd := int(order.Uint32(tiff[4:]))
+		if ifd+2 > len(tiff) {
+			return 1
+		}
+		entries := int(order.Uint16(tiff[ifd:]))
+		for i := range entries {
+			entry := ifd + 2 + i*12
+			if entry+12 > len(tiff) {
+				return 1
+			}
+			if order.Uint16(tiff[entry:]) == orientationTag {
+				return int(order.Uint16(tiff[entry+8:]))
+			}
+		}
+		return 1
+	}
+	return 1
+}
+
+// applyOrientation transforms the image so that it is displayed upright, following EXIF orientation values 1 to 8
+func applyOrientation(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := range dh {
+		for x := range dw {
+			var sx, sy int
+			switch orientation {
+			case 2:
+				sx, sy = w-1-x, y
+			case 3:
+				sx, sy = w-1-x, h-1-y
+			case 4:
+				sx, sy = x, h-1-y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, h-1-x
+			case 7:
+				sx, sy = w-1-y, h-1-x
+			case 8:
+				sx, sy = w-1-y, x
+			}
+			dst.Set(x, y, img.At(bounds.Min.X+sx, bounds.Min.Y+sy))
+		}
+	}
+	return dst
+}
+
+// resizeToFit downscales the image to fit in maxWidth x maxHeight, keeping its aspect ratio.
+// Each destination pixel is the average of the source pixels it covers.
+func resizeToFit(img image.Image, maxWidth, maxHeight int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= maxWidth && h <= maxHeight {
+		return img
+	}
+	scale := min(float64(maxWidth)/float64(w), float64(maxHeight)/float64(h))
+	dw, dh := max(1, int(float64(w)*scale)), max(1, int(float64(h)*scale))
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := range dh {
+		sy0, sy1 := y*h/dh, max((y+1)*h/dh, y*h/dh+1)
+		for x := range dw {
+			sx0, sx1 := x*w/dw, max((x+1)*w/dw, x*w/dw+1)
+			var r, g, b, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					c := color.NRGBAModel.Convert(img.At(bounds.Min.X+sx, bounds.Min.Y+sy)).(color.NRGBA)
+					r, g, b, a = r+uint64(c.R), g+uint64(c.G), b+uint64(c.B), a+uint64(c.A)
+					n++
+				}
+			}
+			dst.SetNRGBA(x, y, color.NRGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n), A: uint8(a / n)}) // #nosec G115
+		}
+	}
+	return dst
+}
@@ -0,0 +1,199 @@
+package kcore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func chdirMedia(t *testing.T) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.MkdirAll("media/images", 0o700))
+}
+
+func encodePNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			img.Set(x, y, color.NRGBA{R: 255, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+// encodeJPEGWithOrientation encodes a JPEG with an EXIF APP1 segment holding the orientation tag
+func encodeJPEGWithOrientation(t *testing.T, width, height int, orientation uint16) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height)), nil))
+	content := buf.Bytes()
+
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08") // big endian, IFD0 at offset 8
+	tiff = binary.BigEndian.AppendUint16(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.BigEndian.AppendUint16(tiff, 3) // SHORT
+	tiff = binary.BigEndian.AppendUint32(tiff, 1)
+	tiff = binary.BigEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+
+	app1 := []byte{0xFF, 0xE1}
+	app1 = binary.BigEndian.AppendUint16(app1, uint16(len(segment)+2)) // #nosec G115
+	app1 = append(app1, segment...)
+	return append(append(append([]byte{}, content[:2]...), app1...), content[2:]...)
+}
+
+func decodeFile(t *testing.T, path string) image.Image {
+	t.Helper()
+	f, err := os.Open(path) // #nosec G304
+	require.NoError(t, err)
+	defer f.Close() //nolint:errcheck
+	img, _, err := image.Decode(f)
+	require.NoError(t, err)
+	return img
+}
+
+func TestImageProcess_variants(t *testing.T) {
+	chdirMedia(t)
+	img := NewImage()
+
+	err := img.Process(bytes.NewReader(encodePNG(t, 400, 100)), DefaultImageConfig)
+
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 400, 100), decodeFile(t, img.Path()).Bounds())
+	assert.Equal(t, image.Rect(0, 0, 200, 50), decodeFile(t, img.VariantPath("thumbnail")).Bounds())
+	assert.Equal(t, image.Rect(0, 0, 400, 100), decodeFile(t, img.VariantPath("medium")).Bounds())
+
+	require.NoError(t, img.Delete())
+	_, err = os.Stat(img.VariantPath("thumbnail"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestImageProcess_notAnImage(t *testing.T) {
+	chdirMedia(t)
+	img := NewImage()
+
+	err := img.Process(strings.NewReader("<html></html>"), DefaultImageConfig)
+
+	assert.ErrorIs(t, err, ErrNotAnImage)
+	_, err = os.Stat(img.Path())
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestImageProcess_tooLarge(t *testing.T) {
+	chdirMedia(t)
+	img := NewImage()
+	config := DefaultImageConfig
+	config.MaxWidth = 100
+
+	err := img.Process(bytes.NewReader(encodePNG(t, 101, 10)), config)
+
+	assert.ErrorIs(t, err, ErrImageTooLarge)
+}
+
+func TestImageProcess_maxSize(t *testing.T) {
+	chdirMedia(t)
+	img := NewImage()
+	content := encodePNG(t, 10, 10)
+	config := DefaultImageConfig
+	config.MaxSize = int64(len(content)) - 1
+
+	err := img.Process(bytes.NewReader(content), config)
+
+	assert.ErrorIs(t, err, ErrFileTooLarge)
+	_, err = os.Stat(img.Path())
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func encodeGIF(t *testing.T, width, height, frames int) []byte {
+	t.Helper()
+	animation := &gif.GIF{}
+	for range frames {
+		animation.Image = append(animation.Image, image.NewPaletted(image.Rect(0, 0, width, height), color.Palette{color.Black, color.White}))
+		animation.Delay = append(animation.Delay, 10)
+	}
+	var buf bytes.Buffer
+	require.NoError(t, gif.EncodeAll(&buf, animation))
+	return buf.Bytes()
+}
+
+func TestImageProcess_animatedGIF(t *testing.T) {
+	chdirMedia(t)
+	img := NewImage()
+	animation := encodeGIF(t, 400, 100, 2)
+
+	err := img.Process(bytes.NewReader(animation), DefaultImageConfig)
+
+	require.NoError(t, err)
+	content, err := os.ReadFile(img.Path())
+	require.NoError(t, err)
+	assert.Equal(t, animation, content, "GIF originals are kept untouched")
+	assert.Equal(t, image.Rect(0, 0, 200, 50), decodeFile(t, img.VariantPath("thumbnail")).Bounds())
+}
+
+func TestImageProcess_exifOrientation(t *testing.T) {
+	chdirMedia(t)
+	img := NewImage()
+
+	err := img.Process(bytes.NewReader(encodeJPEGWithOrientation(t, 40, 20, 6)), DefaultImageConfig)
+
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 20, 40), decodeFile(t, img.Path()).Bounds())
+	content, err := os.ReadFile(img.Path())
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), "Exif")
+}
+
+func TestImageHandler(t *testing.T) {
+	chdirMedia(t)
+	img := NewImage()
+	require.NoError(t, img.Process(bytes.NewReader(encodePNG(t, 10, 10)), DefaultImageConfig))
+	mux := http.NewServeMux()
+	mux.Handle("GET /images/{id}/{variant}", ImageHandler(DefaultImageConfig))
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/images/"+ID(img).String()+"/thumbnail", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "image/png", rr.Header().Get("Content-Type"))
+	assert.Contains(t, rr.Header().Get("Cache-Control"), "immutable")
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/images/"+ID(img).String()+"/huge", nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestImageProcess_gifFrames(t *testing.T) {
+	chdirMedia(t)
+	img := NewImage()
+	animation := encodeGIF(t, 100, 100, 30)
+	config := DefaultImageConfig
+	config.MaxGIFPixels = 100 * 100 * 20
+
+	frames, err := gifFrameCount(animation)
+	require.NoError(t, err)
+	assert.Equal(t, 30, frames)
+	_, err = gifFrameCount(animation[:len(animation)-10])
+	assert.Error(t, err)
+
+	err = img.Process(bytes.NewReader(animation), config)
+
+	assert.ErrorIs(t, err, ErrImageTooLarge)
+	_, err = os.Stat(img.Path())
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
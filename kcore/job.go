@@ -0,0 +1,204 @@
+package kcore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"golang.org/x/exp/slog"
+)
+
+var ErrNoJobHandler = errors.New("no handler for job kind")
+
+// A Job is a unit of background work, such as sending an email after a command commits
+type Job struct {
+	ID        ID
+	Kind      string
+	Payload   json.RawMessage
+	Attempts  int
+	RunAt     time.Time
+	LastError string
+}
+
+// NewJob creates a job to run as soon as possible, with its payload encoded in JSON
+func NewJob(kind string, payload any) Job {
+	content, err := json.Marshal(payload)
+	Expect(err, "error marshalling job payload")
+	return Job{ID: NewID(), Kind: kind, Payload: content, RunAt: time.Now()}
+}
+
+// Decode unmarshals the job payload
+func (j Job) Decode(payload any) error {
+	if err := json.Unmarshal(j.Payload, payload); err != nil {
+		return Wrap(err, "error unmarshalling job payload")
+	}
+	return nil
+}
+
+// A JobQueue stores jobs until they are done or dead
+type JobQueue interface {
+	// Enqueue ignores a job whose ID is already queued
+	Enqueue(ctx context.Context, job Job) error
+	// Claim locks the next due job and increments its attempts, false if no job is due
+	Claim(ctx context.Context, now time.Time) (Job, bool, error)
+	Complete(ctx context.Context, id ID) error
+	Retry(ctx context.Context, id ID, runAt time.Time, lastError string) error
+	// DeadLetter keeps the job for inspection, without running it again
+	DeadLetter(ctx context.Context, id ID, lastError string) error
+}
+
+// A JobHandler runs a job with a logger carrying the job attributes
+type JobHandler func(ctx context.Context, logger *slog.Logger, job Job) error
+
+type jobSchedule struct {
+	kind    string
+	cron    Cron
+	payload any
+}
+
+// JobRunner runs jobs from the queue in worker goroutines.
+// Failed jobs are retried with exponential backoff, then dead-lettered after MaxAttempts.
+type JobRunner struct {
+	Queue        JobQueue
+	Workers      int
+	MaxAttempts  int
+	PollInterval time.Duration
+	Backoff      func(attempt int) time.Duration
+	Now          func() time.Time // injectable time provider
+
+	handlers  map[string]JobHandler
+	schedules []jobSchedule
+}
+
+func NewJobRunner(queue JobQueue) *JobRunner {
+	return &JobRunner{
+		Queue:        queue,
+		Workers:      4,
+		MaxAttempts:  5,
+		PollInterval: time.Second,
+		Backoff:      ExponentialBackoff(time.Second, time.Hour),
+		Now:          time.Now,
+		handlers:     map[string]JobHandler{},
+	}
+}
+
+// ExponentialBackoff doubles the delay after each attempt, from base up to limit
+func ExponentialBackoff(base time.Duration, limit time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		delay := base
+		for i := 1; i < attempt && delay < limit; i++ {
+			delay *= 2
+		}
+		return min(delay, limit)
+	}
+}
+
+// Handle registers the handler of a job kind, before calling Run
+func (r *JobRunner) Handle(kind string, handler JobHandler) {
+	r.handlers[kind] = handler
+}
+
+// Schedule enqueues a job of the given kind following a cron expression, before calling Run.
+// Scheduled jobs have an ID derived from their kind and time, so several runners don't enqueue duplicates.
+func (r *JobRunner) Schedule(kind string, spec string, payload any) error {
+	cron, err := ParseCron(spec)
+	if err != nil {
+		return err
+	}
+	r.schedules = append(r.schedules, jobSchedule{kind: kind, cron: cron, payload: payload})
+	return nil
+}
+
+// Run starts the workers and the scheduler, and blocks until the context is cancelled and running jobs are finished
+func (r *JobRunner) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for range r.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.work(ctx)
+		}()
+	}
+	for _, schedule := range r.schedules {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.schedule(ctx, schedule)
+		}()
+	}
+	wg.Wait()
+}
+
+func (r *JobRunner) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		ran, err := r.RunOnce(ctx)
+		if err != nil {
+			slog.Error(Wrap(err, "error running job").Error())
+		}
+		if ran && err == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(r.PollInterval):
+		}
+	}
+}
+
+func (r *JobRunner) schedule(ctx context.Context, schedule jobSchedule) {
+	for {
+		next := schedule.cron.Next(r.Now())
+		if next.IsZero() {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(next.Sub(r.Now())):
+		}
+		job := NewJob(schedule.kind, schedule.payload)
+		job.ID = ID{uuid.NewV5(uuid.NamespaceOID, fmt.Sprintf("%s@%d", schedule.kind, next.Unix()))}
+		job.RunAt = next
+		if err := r.Queue.Enqueue(ctx, job); err != nil {
+			slog.Error(Wrap(err, "error enqueuing scheduled job").Error(), slog.String("job", schedule.kind))
+		}
+	}
+}
+
+// RunOnce claims and runs the next due job, and reports whether a job was run
+func (r *JobRunner) RunOnce(ctx context.Context) (bool, error) {
+	job, ok, err := r.Queue.Claim(ctx, r.Now())
+	if err != nil || !ok {
+		return false, err
+	}
+	logger := slog.With(slog.String("job", job.Kind), slog.String("jobId", job.ID.String()), slog.Int("attempt", job.Attempts))
+
+	jobErr := r.run(ctx, logger, job)
+	if jobErr == nil {
+		return true, r.Queue.Complete(ctx, job.ID)
+	}
+	if job.Attempts >= r.MaxAttempts || errors.Is(jobErr, ErrNoJobHandler) {
+		logger.Error("job dead-lettered", slog.String("error", jobErr.Error()))
+		return true, r.Queue.DeadLetter(ctx, job.ID, jobErr.Error())
+	}
+	runAt := r.Now().Add(r.Backoff(job.Attempts))
+	logger.Warn("job failed, retrying", slog.String("error", jobErr.Error()), slog.Time("runAt", runAt))
+	return true, r.Queue.Retry(ctx, job.ID, runAt, jobErr.Error())
+}
+
+func (r *JobRunner) run(ctx context.Context, logger *slog.Logger, job Job) (err error) {
+	handler, ok := r.handlers[job.Kind]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNoJobHandler, job.Kind)
+	}
+	defer func() {
+		if rcv := recover(); rcv != nil {
+			err = fmt.Errorf("job panicked: %v", rcv)
+		}
+	}()
+	return handler(ctx, logger, job)
+}
@@ -0,0 +1,201 @@
+package kcore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	jobPending = "pending"
+	jobRunning = "running"
+	jobDone    = "done"
+	jobDead    = "dead"
+)
+
+// How long a claimed job stays locked, after which a crashed worker's job is claimed again
+const jobLockDuration = 10 * time.Minute
+
+type memoryJob struct {
+	Job
+	status string
+}
+
+// MemoryJobQueue keeps jobs in memory, for tests and single process apps
+type MemoryJobQueue struct {
+	mutex sync.Mutex
+	jobs  []*memoryJob
+}
+
+func NewMemoryJobQueue() *MemoryJobQueue {
+	return &MemoryJobQueue{}
+}
+
+func (q *MemoryJobQueue) find(id ID) *memoryJob {
+	for _, job := range q.jobs {
+		if job.ID == id {
+			return job
+		}
+	}
+	return nil
+}
+
+func (q *MemoryJobQueue) Enqueue(ctx context.Context, job Job) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.find(job.ID) == nil {
+		q.jobs = append(q.jobs, &memoryJob{Job: job, status: jobPending})
+	}
+	return nil
+}
+
+func (q *MemoryJobQueue) Claim(ctx context.Context, now time.Time) (Job, bool, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	var next *memoryJob
+	for _, job := range q.jobs {
+		if job.status == jobPending && !job.RunAt.After(now) && (next == nil || job.RunAt.Before(next.RunAt)) {
+			next = job
+		}
+	}
+	if next == nil {
+		return Job{}, false, nil
+	}
+	next.status = jobRunning
+	next.Attempts++
+	return next.Job, true, nil
+}
+
+func (q *MemoryJobQueue) update(id ID, update func(job *memoryJob)) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	job := q.find(id)
+	if job == nil {
+		return Wrap(sql.ErrNoRows, "job "+id.String())
+	}
+	update(job)
+	return nil
+}
+
+func (q *MemoryJobQueue) Complete(ctx context.Context, id ID) error {
+	return q.update(id, func(job *memoryJob) { job.status = jobDone })
+}
+
+func (q *MemoryJobQueue) Retry(ctx context.Context, id ID, runAt time.Time, lastError string) error {
+	return q.update(id, func(job *memoryJob) {
+		job.status, job.RunAt, job.LastError = jobPending, runAt, lastError
+	})
+}
+
+func (q *MemoryJobQueue) DeadLetter(ctx context.Context, id ID, lastError string) error {
+	return q.update(id, func(job *memoryJob) { job.status, job.LastError = jobDead, lastError })
+}
+
+// DeadJobs returns the dead-lettered jobs
+func (q *MemoryJobQueue) DeadJobs() []Job {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	jobs := []Job{}
+	for _, job := range q.jobs {
+		if job.status == jobDead {
+			jobs = append(jobs, job.Job)
+		}
+	}
+	return jobs
+}
+
+// SQLJobQueue is a transactional outbox: enqueue jobs with EnqueueTx in the transaction of the command,
+// so that they are only run if the command commits. Complete, Retry and DeadLetter only update running jobs, so that
+// a worker whose lock expired never overrides the outcome of another. It uses a jobs table:
+//
+//	CREATE TABLE jobs (
+//	  id TEXT PRIMARY KEY,
+//	  kind TEXT NOT NULL,
+//	  payload TEXT NOT NULL,
+//	  status TEXT NOT NULL, -- pending, running, done or dead
+//	  attempts INTEGER NOT NULL,
+//	  run_at TIMESTAMP NOT NULL,
+//	  locked_until TIMESTAMP,
+//	  last_error TEXT NOT NULL
+//	);
+//	CREATE INDEX jobs_due ON jobs (status, run_at);
+type SQLJobQueue struct {
+	DB      *sql.DB
+	Dialect SQLDialect
+}
+
+type sqlExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func (q SQLJobQueue) Enqueue(ctx context.Context, job Job) error {
+	return q.enqueue(ctx, q.DB, job)
+}
+
+// EnqueueTx enqueues the job in the transaction of the command
+func (q SQLJobQueue) EnqueueTx(ctx context.Context, tx *sql.Tx, job Job) error {
+	return q.enqueue(ctx, tx, job)
+}
+
+func (q SQLJobQueue) enqueue(ctx context.Context, db sqlExecutor, job Job) error {
+	_, err := db.ExecContext(ctx, q.Dialect.rebind(`INSERT INTO jobs (id, kind, payload, status, attempts, run_at, last_error) VALUES (?, ?, ?, ?, ?, ?, ?) ON CONFLICT (id) DO NOTHING`),
+		job.ID, job.Kind, string(job.Payload), jobPending, job.Attempts, job.RunAt.UTC(), job.LastError)
+	if err != nil {
+		return Wrap(err, "error inserting job")
+	}
+	return nil
+}
+
+func (q SQLJobQueue) Claim(ctx context.Context, now time.Time) (Job, bool, error) {
+	now = now.UTC()
+	// Another worker can claim the same job between the select and the update: try again with the next one
+	for range 3 {
+		var job Job
+		var payload string
+		row := q.DB.QueryRowContext(ctx, q.Dialect.rebind(`SELECT id, kind, payload, attempts, run_at, last_error FROM jobs
+			WHERE (status = ? AND run_at <= ?) OR (status = ? AND locked_until < ?)
+			ORDER BY run_at LIMIT 1`), jobPending, now, jobRunning, now)
+		err := row.Scan(&job.ID, &job.Kind, &payload, &job.Attempts, &job.RunAt, &job.LastError)
+		if errors.Is(err, sql.ErrNoRows) {
+			return Job{}, false, nil
+		}
+		if err != nil {
+			return Job{}, false, Wrap(err, "error selecting job")
+		}
+		job.Payload = json.RawMessage(payload)
+
+		// The status guard keeps a job completed since the select, by the worker whose lock expired, from running again
+		result, err := q.DB.ExecContext(ctx, q.Dialect.rebind(`UPDATE jobs SET status = ?, attempts = attempts + 1, locked_until = ? WHERE id = ? AND attempts = ? AND status IN (?, ?)`),
+			jobRunning, now.Add(jobLockDuration), job.ID, job.Attempts, jobPending, jobRunning)
+		if err != nil {
+			return Job{}, false, Wrap(err, "error claiming job")
+		}
+		if claimed, err := result.RowsAffected(); err == nil && claimed == 1 {
+			job.Attempts++
+			return job, true, nil
+		}
+	}
+	return Job{}, false, nil
+}
+
+func (q SQLJobQueue) Complete(ctx context.Context, id ID) error {
+	return q.exec(ctx, `UPDATE jobs SET status = ?, locked_until = NULL WHERE id = ? AND status = ?`, jobDone, id, jobRunning)
+}
+
+func (q SQLJobQueue) Retry(ctx context.Context, id ID, runAt time.Time, lastError string) error {
+	return q.exec(ctx, `UPDATE jobs SET status = ?, run_at = ?, last_error = ?, locked_until = NULL WHERE id = ? AND status = ?`, jobPending, runAt.UTC(), lastError, id, jobRunning)
+}
+
+func (q SQLJobQueue) DeadLetter(ctx context.Context, id ID, lastError string) error {
+	return q.exec(ctx, `UPDATE jobs SET status = ?, last_error = ?, locked_until = NULL WHERE id = ? AND status = ?`, jobDead, lastError, id, jobRunning)
+}
+
+func (q SQLJobQueue) exec(ctx context.Context, query string, args ...any) error {
+	if _, err := q.DB.ExecContext(ctx, q.Dialect.rebind(query), args...); err != nil {
+		return Wrap(err, "error updating job")
+	}
+	return nil
+}
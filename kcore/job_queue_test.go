@@ -0,0 +1,159 @@
+package kcore
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const jobsSchema = `CREATE TABLE jobs (
+	id TEXT PRIMARY KEY,
+	kind TEXT NOT NULL,
+	payload TEXT NOT NULL,
+	status TEXT NOT NULL,
+	attempts INTEGER NOT NULL,
+	run_at TIMESTAMP NOT NULL,
+	locked_until TIMESTAMP,
+	last_error TEXT NOT NULL
+)`
+
+func jobStatus(t *testing.T, db *sql.DB, id ID) (string, string) {
+	t.Helper()
+	var status, lastError string
+	require.NoError(t, db.QueryRow(`SELECT status, last_error FROM jobs WHERE id = ?`, id).Scan(&status, &lastError))
+	return status, lastError
+}
+
+func TestSQLJobQueue_claimAndComplete(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t, jobsSchema)
+	queue := SQLJobQueue{DB: db}
+	now := time.Date(2026, time.May, 12, 10, 0, 0, 0, time.UTC)
+	later := NewJob("email", testEmail{To: "bob@example.com"})
+	later.RunAt = now.Add(time.Hour)
+	due := NewJob("email", testEmail{To: "alice@example.com"})
+	due.RunAt = now.Add(-time.Minute)
+	require.NoError(t, queue.Enqueue(ctx, later))
+	require.NoError(t, queue.Enqueue(ctx, due))
+	require.NoError(t, queue.Enqueue(ctx, due), "enqueuing the same job twice is a no-op")
+
+	job, ok, err := queue.Claim(ctx, now)
+
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, due.ID, job.ID)
+	assert.Equal(t, 1, job.Attempts)
+	var email testEmail
+	require.NoError(t, job.Decode(&email))
+	assert.Equal(t, "alice@example.com", email.To)
+	_, ok, err = queue.Claim(ctx, now)
+	require.NoError(t, err)
+	assert.False(t, ok, "running and future jobs are not claimed")
+
+	require.NoError(t, queue.Complete(ctx, job.ID))
+	status, _ := jobStatus(t, db, job.ID)
+	assert.Equal(t, jobDone, status)
+}
+
+func TestSQLJobQueue_retryAndDeadLetter(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t, jobsSchema)
+	queue := SQLJobQueue{DB: db}
+	now := time.Date(2026, time.May, 12, 10, 0, 0, 0, time.UTC)
+	job := NewJob("email", testEmail{To: "alice@example.com"})
+	job.RunAt = now
+	require.NoError(t, queue.Enqueue(ctx, job))
+
+	claimed, ok, err := queue.Claim(ctx, now)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.NoError(t, queue.Retry(ctx, claimed.ID, now.Add(time.Minute), "smtp unavailable"))
+
+	_, ok, err = queue.Claim(ctx, now)
+	require.NoError(t, err)
+	assert.False(t, ok, "retried jobs wait for their run time")
+	claimed, ok, err = queue.Claim(ctx, now.Add(time.Minute))
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, 2, claimed.Attempts)
+	assert.Equal(t, "smtp unavailable", claimed.LastError)
+
+	require.NoError(t, queue.DeadLetter(ctx, claimed.ID, "smtp rejected"))
+	status, lastError := jobStatus(t, db, claimed.ID)
+	assert.Equal(t, jobDead, status)
+	assert.Equal(t, "smtp rejected", lastError)
+}
+
+func TestSQLJobQueue_expiredLock(t *testing.T) {
+	ctx := context.Background()
+	queue := SQLJobQueue{DB: openSQLite(t, jobsSchema)}
+	now := time.Date(2026, time.May, 12, 10, 0, 0, 0, time.UTC)
+	job := NewJob("email", testEmail{To: "alice@example.com"})
+	job.RunAt = now
+	require.NoError(t, queue.Enqueue(ctx, job))
+	_, ok, err := queue.Claim(ctx, now)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// The worker died without completing the job
+	claimed, ok, err := queue.Claim(ctx, now.Add(jobLockDuration+time.Second))
+
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, job.ID, claimed.ID)
+	assert.Equal(t, 2, claimed.Attempts)
+}
+
+func TestSQLJobQueue_lateWorker(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t, jobsSchema)
+	queue := SQLJobQueue{DB: db}
+	now := time.Date(2026, time.May, 12, 10, 0, 0, 0, time.UTC)
+	job := NewJob("email", testEmail{To: "alice@example.com"})
+	job.RunAt = now
+	require.NoError(t, queue.Enqueue(ctx, job))
+	_, ok, err := queue.Claim(ctx, now)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.NoError(t, queue.Complete(ctx, job.ID))
+
+	// A worker whose lock expired reports a failure after the job was completed
+	require.NoError(t, queue.Retry(ctx, job.ID, now, "timeout"))
+	require.NoError(t, queue.DeadLetter(ctx, job.ID, "timeout"))
+
+	status, lastError := jobStatus(t, db, job.ID)
+	assert.Equal(t, jobDone, status)
+	assert.Empty(t, lastError)
+	_, ok, err = queue.Claim(ctx, now.Add(jobLockDuration+time.Second))
+	require.NoError(t, err)
+	assert.False(t, ok, "done jobs are never claimed again")
+}
+
+func TestSQLJobQueue_EnqueueTx(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t, jobsSchema)
+	queue := SQLJobQueue{DB: db}
+	now := time.Date(2026, time.May, 12, 10, 0, 0, 0, time.UTC)
+	job := NewJob("email", testEmail{To: "alice@example.com"})
+	job.RunAt = now
+
+	tx, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	require.NoError(t, queue.EnqueueTx(ctx, tx, job))
+	require.NoError(t, tx.Rollback())
+	_, ok, err := queue.Claim(ctx, now)
+	require.NoError(t, err)
+	assert.False(t, ok, "jobs of rolled back commands are not run")
+
+	tx, err = db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	require.NoError(t, queue.EnqueueTx(ctx, tx, job))
+	require.NoError(t, tx.Commit())
+	_, ok, err = queue.Claim(ctx, now)
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
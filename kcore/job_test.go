@@ -0,0 +1,170 @@
+package kcore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
+)
+
+type testEmail struct {
+	To string `json:"to"`
+}
+
+func newTestRunner(now *time.Time) (*JobRunner, *MemoryJobQueue) {
+	queue := NewMemoryJobQueue()
+	runner := NewJobRunner(queue)
+	runner.MaxAttempts = 3
+	runner.Now = func() time.Time { return *now }
+	return runner, queue
+}
+
+func TestJobRunner_success(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, time.May, 12, 10, 0, 0, 0, time.UTC)
+	runner, queue := newTestRunner(&now)
+	var sentTo string
+	runner.Handle("email", func(ctx context.Context, logger *slog.Logger, job Job) error {
+		var email testEmail
+		require.NoError(t, job.Decode(&email))
+		sentTo = email.To
+		return nil
+	})
+	job := NewJob("email", testEmail{To: "john@example.com"})
+	job.RunAt = now
+	require.NoError(t, queue.Enqueue(ctx, job))
+
+	ran, err := runner.RunOnce(ctx)
+
+	require.NoError(t, err)
+	assert.True(t, ran)
+	assert.Equal(t, "john@example.com", sentTo)
+	ran, err = runner.RunOnce(ctx)
+	require.NoError(t, err)
+	assert.False(t, ran)
+}
+
+func TestJobRunner_retriesThenDeadLetters(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, time.May, 12, 10, 0, 0, 0, time.UTC)
+	runner, queue := newTestRunner(&now)
+	attempts := 0
+	runner.Handle("webhook", func(ctx context.Context, logger *slog.Logger, job Job) error {
+		attempts++
+		return errors.New("connection refused")
+	})
+	job := NewJob("webhook", nil)
+	job.RunAt = now
+	require.NoError(t, queue.Enqueue(ctx, job))
+
+	_, err := runner.RunOnce(ctx)
+	require.NoError(t, err)
+	ran, err := runner.RunOnce(ctx)
+	require.NoError(t, err)
+	assert.False(t, ran, "job should wait for its backoff")
+
+	now = now.Add(time.Second)
+	_, err = runner.RunOnce(ctx)
+	require.NoError(t, err)
+	now = now.Add(2 * time.Second)
+	_, err = runner.RunOnce(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, attempts)
+	dead := queue.DeadJobs()
+	require.Len(t, dead, 1)
+	assert.Equal(t, "connection refused", dead[0].LastError)
+}
+
+func TestJobRunner_panicAndUnknownKind(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, time.May, 12, 10, 0, 0, 0, time.UTC)
+	runner, queue := newTestRunner(&now)
+	runner.MaxAttempts = 1
+	runner.Handle("panic", func(ctx context.Context, logger *slog.Logger, job Job) error { panic("boom") })
+	require.NoError(t, queue.Enqueue(ctx, Job{ID: NewID(), Kind: "panic", RunAt: now}))
+	require.NoError(t, queue.Enqueue(ctx, Job{ID: NewID(), Kind: "unknown", RunAt: now}))
+
+	for range 2 {
+		_, err := runner.RunOnce(ctx)
+		require.NoError(t, err)
+	}
+
+	dead := queue.DeadJobs()
+	require.Len(t, dead, 2)
+	assert.Equal(t, "job panicked: boom", dead[0].LastError)
+	assert.ErrorContains(t, errors.New(dead[1].LastError), ErrNoJobHandler.Error())
+}
+
+func TestJobRunner_run(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	queue := NewMemoryJobQueue()
+	runner := NewJobRunner(queue)
+	runner.PollInterval = time.Millisecond
+	done := make(chan string, 1)
+	runner.Handle("email", func(ctx context.Context, logger *slog.Logger, job Job) error {
+		done <- job.Kind
+		return nil
+	})
+	require.NoError(t, queue.Enqueue(ctx, NewJob("email", nil)))
+
+	go func() {
+		assert.Equal(t, "email", <-done)
+		cancel()
+	}()
+	runner.Run(ctx)
+}
+
+func TestMemoryJobQueue_duplicateID(t *testing.T) {
+	ctx := context.Background()
+	queue := NewMemoryJobQueue()
+	job := NewJob("email", nil)
+
+	require.NoError(t, queue.Enqueue(ctx, job))
+	require.NoError(t, queue.Enqueue(ctx, job))
+
+	assert.Len(t, queue.jobs, 1)
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(time.Second, time.Minute)
+
+	assert.Equal(t, time.Second, backoff(1))
+	assert.Equal(t, 2*time.Second, backoff(2))
+	assert.Equal(t, 32*time.Second, backoff(6))
+	assert.Equal(t, time.Minute, backoff(20))
+}
+
+func TestCron_Next(t *testing.T) {
+	from := time.Date(2026, time.May, 12, 10, 7, 30, 0, time.UTC) // Tuesday
+	cases := map[string]time.Time{
+		"* * * * *":       time.Date(2026, time.May, 12, 10, 8, 0, 0, time.UTC),
+		"*/15 * * * *":    time.Date(2026, time.May, 12, 10, 15, 0, 0, time.UTC),
+		"0 9 * * *":       time.Date(2026, time.May, 13, 9, 0, 0, 0, time.UTC),
+		"30 8 * * 1-5":    time.Date(2026, time.May, 13, 8, 30, 0, 0, time.UTC),
+		"0 0 1 * *":       time.Date(2026, time.June, 1, 0, 0, 0, 0, time.UTC),
+		"0 0 29 2 *":      time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC),
+		"0 12 1,15 1,7 *": time.Date(2026, time.July, 1, 12, 0, 0, 0, time.UTC),
+		"0 0 13 * 1":      time.Date(2026, time.May, 13, 0, 0, 0, 0, time.UTC), // either day matches
+		"0 0 */2 * 1":     time.Date(2026, time.May, 18, 0, 0, 0, 0, time.UTC), // a step on * is not a restriction
+		"0 0 1 * */2":     time.Date(2026, time.June, 1, 0, 0, 0, 0, time.UTC),
+		"0 0 * * 7":       time.Date(2026, time.May, 17, 0, 0, 0, 0, time.UTC), // Sunday
+		"0 0 * * 5-7":     time.Date(2026, time.May, 15, 0, 0, 0, 0, time.UTC),
+	}
+	for spec, expected := range cases {
+		cron, err := ParseCron(spec)
+		require.NoError(t, err, spec)
+		assert.Equal(t, expected, cron.Next(from), spec)
+	}
+}
+
+func TestParseCron_invalid(t *testing.T) {
+	for _, spec := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "a * * * *", "5-1 * * * *", "* * * * 8"} {
+		_, err := ParseCron(spec)
+		assert.ErrorIs(t, err, ErrInvalidCron, spec)
+	}
+}
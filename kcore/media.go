@@ -0,0 +1,147 @@
+package kcore
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrSignatureInvalid = errors.New("invalid url signature")
+	ErrSignatureExpired = errors.New("url signature expired")
+)
+
+// Stored file names are an ID or a hash, with an optional extension: anything else could escape media/files
+var fileNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+(\.[A-Za-z0-9]+)?$`)
+
+// MediaServer serves stored files and images.
+// Private media are only served through URLs signed with Secret, see SignURL.
+type MediaServer struct {
+	Images  ImageConfig
+	Private bool
+	Secret  []byte
+	Now     func() time.Time // injectable time provider
+}
+
+func (s MediaServer) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+func (s MediaServer) signature(path string, download string, expires string) string {
+	mac := hmac.New(sha256.New, s.Secret)
+	mac.Write([]byte(path + "\n" + download + "\n" + expires))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// SignURL returns path with a signature valid for ttl. The signature covers the download query parameter of path,
+// such as "/files/x.pdf?download=invoice.pdf", so that the name of the attachment can't be changed.
+func (s MediaServer) SignURL(path string, ttl time.Duration) string {
+	Assert(len(s.Secret) > 0, "media server needs a secret to sign urls")
+	u, err := url.Parse(path)
+	Expect(err, "error parsing media url")
+	expires := strconv.FormatInt(s.now().Add(ttl).Unix(), 10)
+	query := u.Query()
+	query.Set("expires", expires)
+	query.Set("signature", s.signature(u.Path, query.Get("download"), expires))
+	return u.Path + "?" + query.Encode()
+}
+
+// VerifyURL checks the signature added by SignURL. Without a secret, anyone could sign urls: none are valid.
+func (s MediaServer) VerifyURL(r *http.Request) error {
+	if len(s.Secret) == 0 {
+		return ErrSignatureInvalid
+	}
+	expires := r.URL.Query().Get("expires")
+	expected := s.signature(r.URL.Path, r.URL.Query().Get("download"), expires)
+	if !hmac.Equal([]byte(expected), []byte(r.URL.Query().Get("signature"))) {
+		return ErrSignatureInvalid
+	}
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrSignatureInvalid
+	}
+	if s.now().Unix() > expiresAt {
+		return ErrSignatureExpired
+	}
+	return nil
+}
+
+func (s MediaServer) guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.Private {
+			if err := s.VerifyURL(r); err != nil {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+			// Prevent shared caches from keeping private media
+			w.Header().Set("Cache-Control", "private, max-age=3600")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Extensions of the files served inline, other uploads such as HTML or SVG are attachments so that they can't run
+// scripts on the origin of the app
+var inlineExtensions = map[string]bool{".avif": true, ".bmp": true, ".gif": true, ".ico": true, ".jpg": true, ".png": true, ".webp": true}
+
+// FileHandler serves files from the {file} path value, images inline and other files as attachments.
+// A download query parameter sends the file as an attachment, named after its value.
+//
+//	handler.Handle("GET /files/{file}", server.FileHandler())
+func (s MediaServer) FileHandler() http.Handler {
+	return s.guard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("file")
+		if !fileNameRegexp.MatchString(name) {
+			http.NotFound(w, r)
+			return
+		}
+		if download := r.URL.Query().Get("download"); download != "" {
+			w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": download}))
+		} else if !inlineExtensions[filepath.Ext(name)] {
+			w.Header().Set("Content-Disposition", "attachment")
+		}
+		if w.Header().Get("Cache-Control") == "" {
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		}
+		serveMedia(w, r, File(name).Path())
+	}))
+}
+
+// ImageHandler serves image variants, see kcore.ImageHandler
+//
+//	handler.Handle("GET /images/{id}/{variant}", server.ImageHandler())
+func (s MediaServer) ImageHandler() http.Handler {
+	return s.guard(ImageHandler(s.Images))
+}
+
+// serveMedia writes the file at path, handling Range, ETag and Last-Modified
+func serveMedia(w http.ResponseWriter, r *http.Request, path string) {
+	f, err := os.Open(path) // #nosec G304 paths are built from validated names
+	if errors.Is(err, os.ErrNotExist) {
+		http.NotFound(w, r)
+		return
+	}
+	Expect(err, "error opening media")
+	defer f.Close() //nolint:errcheck
+	stat, err := f.Stat()
+	Expect(err, "error reading media info")
+
+	w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, stat.ModTime().UnixNano(), stat.Size()))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// Opened directly, uploads run without scripts and outside of the origin of the app
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	http.ServeContent(w, r, stat.Name(), stat.ModTime(), f)
+}
@@ -0,0 +1,146 @@
+package kcore
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMediaMux(server MediaServer) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("GET /files/{file}", server.FileHandler())
+	return mux
+}
+
+func storeFile(t *testing.T, content string) File {
+	t.Helper()
+	chdirFiles(t)
+	file := NewFile(".txt")
+	require.NoError(t, os.WriteFile(file.Path(), []byte(content), 0o600))
+	return file
+}
+
+func TestFileHandler_range(t *testing.T) {
+	file := storeFile(t, "0123456789")
+	mux := newMediaMux(MediaServer{})
+
+	r := httptest.NewRequest(http.MethodGet, "/files/"+string(file), nil)
+	r.Header.Set("Range", "bytes=2-4")
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, r)
+
+	assert.Equal(t, http.StatusPartialContent, rr.Code)
+	assert.Equal(t, "234", rr.Body.String())
+	assert.NotEmpty(t, rr.Header().Get("Last-Modified"))
+}
+
+func TestFileHandler_etag(t *testing.T) {
+	file := storeFile(t, "0123456789")
+	mux := newMediaMux(MediaServer{})
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/files/"+string(file), nil))
+	etag := rr.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	r := httptest.NewRequest(http.MethodGet, "/files/"+string(file), nil)
+	r.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, r)
+
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+}
+
+func TestFileHandler_download(t *testing.T) {
+	file := storeFile(t, "content")
+	mux := newMediaMux(MediaServer{})
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/files/"+string(file)+"?download=notes.txt", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `attachment; filename=notes.txt`, rr.Header().Get("Content-Disposition"))
+}
+
+func TestFileHandler_inline(t *testing.T) {
+	chdirFiles(t)
+	mux := newMediaMux(MediaServer{})
+	for name, disposition := range map[string]string{"a.png": "", "a.jpg": "", "a.html": "attachment", "a.svg": "attachment", "a.txt": "attachment"} {
+		require.NoError(t, os.WriteFile(File(name).Path(), []byte("<svg><script>alert(1)</script></svg>"), 0o600))
+
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/files/"+name, nil))
+
+		assert.Equal(t, disposition, rr.Header().Get("Content-Disposition"), name)
+		assert.Equal(t, "sandbox", rr.Header().Get("Content-Security-Policy"), name)
+	}
+}
+
+func TestFileHandler_invalidName(t *testing.T) {
+	storeFile(t, "content")
+	mux := newMediaMux(MediaServer{})
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/files/..%2F..%2Fgo.mod", nil))
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestFileHandler_signedURL(t *testing.T) {
+	file := storeFile(t, "secret content")
+	now := time.Date(2026, time.May, 12, 0, 0, 0, 0, time.UTC)
+	server := MediaServer{Private: true, Secret: []byte("secret"), Now: func() time.Time { return now }}
+	mux := newMediaMux(server)
+	signed := server.SignURL("/files/"+string(file), time.Hour)
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, signed, nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "secret content", rr.Body.String())
+	assert.Equal(t, "private, max-age=3600", rr.Header().Get("Cache-Control"))
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/files/"+string(file), nil))
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	now = now.Add(2 * time.Hour)
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, signed, nil))
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Contains(t, rr.Body.String(), ErrSignatureExpired.Error())
+}
+
+func TestFileHandler_signedDownload(t *testing.T) {
+	file := storeFile(t, "secret content")
+	now := time.Date(2026, time.May, 12, 0, 0, 0, 0, time.UTC)
+	server := MediaServer{Private: true, Secret: []byte("secret"), Now: func() time.Time { return now }}
+	mux := newMediaMux(server)
+	signed := server.SignURL("/files/"+string(file)+"?download=notes.txt", time.Hour)
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, signed, nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `attachment; filename=notes.txt`, rr.Header().Get("Content-Disposition"))
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, strings.Replace(signed, "notes.txt", "invoice.exe", 1), nil))
+	assert.Equal(t, http.StatusForbidden, rr.Code, "the download name is signed")
+}
+
+func TestFileHandler_signedURLWithoutSecret(t *testing.T) {
+	file := storeFile(t, "secret content")
+	now := time.Date(2026, time.May, 12, 0, 0, 0, 0, time.UTC)
+	mux := newMediaMux(MediaServer{Private: true, Now: func() time.Time { return now }})
+	// Anyone can compute a signature with an empty key
+	signed := MediaServer{Secret: []byte{}, Now: func() time.Time { return now }}.signature("/files/"+string(file), "", "1778547600")
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/files/"+string(file)+"?expires=1778547600&signature="+signed, nil))
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Contains(t, rr.Body.String(), ErrSignatureInvalid.Error())
+}
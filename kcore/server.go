@@ -0,0 +1,269 @@
+package kcore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+var (
+	ErrNoInheritedListener = errors.New("no inherited listener")
+	ErrNotSocket           = errors.New("not a socket")
+	ErrSocketInUse         = errors.New("socket is in use by a running server")
+	ErrDuplicatePath       = errors.New("endpoints share a path")
+)
+
+// First file descriptor passed by systemd socket activation
+const systemdFirstFD = 3
+
+type namedHook struct {
+	name string
+	hook func(ctx context.Context) error
+}
+
+// Server runs an http.Server with sane timeouts until the context is cancelled or SIGINT/SIGTERM is received,
+// then drains connections and runs the shutdown hooks.
+//
+// Addr is "host:port", "unix:/path/to.sock", "fd:N" for an inherited file descriptor, or "systemd" for socket activation.
+type Server struct {
+	Addr              string
+	Handler           http.Handler
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	// DrainDelay keeps serving, with readiness failing, so that load balancers stop sending traffic before shutdown
+	DrainDelay      time.Duration
+	ShutdownTimeout time.Duration
+	LivenessPath    string
+	ReadinessPath   string
+	// The health report and build info are disabled by default, as they expose internals on the public listener
+	HealthPath  string
+	VersionPath string
+	// Readiness checks, also reported by the health endpoint
+	Checks *HealthChecks
+
+	mutex    sync.Mutex
+	hooks    []namedHook
+	draining atomic.Bool
+}
+
+func NewServer(addr string, handler http.Handler) *Server {
+	return &Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+		ShutdownTimeout:   30 * time.Second,
+		LivenessPath:      "/livez",
+		ReadinessPath:     "/readyz",
+		Checks:            NewHealthChecks(),
+	}
+}
+
+// OnShutdown registers a hook, such as stopping job workers or closing the database pool.
+// Hooks run after the HTTP server is drained, in reverse order of registration.
+func (s *Server) OnShutdown(name string, hook func(ctx context.Context) error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.hooks = append(s.hooks, namedHook{name: name, hook: hook})
+}
+
+// AddReadinessCheck registers a check that must pass for the server to receive traffic
+func (s *Server) AddReadinessCheck(name string, check HealthCheck) {
+	s.Checks.Add(name, check, 0)
+}
+
+// LivenessHandler reports that the process is running
+func (s *Server) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok\n"))
+	})
+}
+
+// ReadinessHandler fails while the server is draining or when a readiness check fails
+func (s *Server) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.draining.Load() {
+			http.Error(w, "draining", http.StatusServiceUnavailable)
+			return
+		}
+		report := s.Checks.Run(r.Context())
+		if !report.Healthy {
+			failing := []string{}
+			for name, result := range report.Checks {
+				if !result.Healthy {
+					failing = append(failing, name)
+				}
+			}
+			sort.Strings(failing)
+			http.Error(w, failing[0]+" is not ready", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok\n"))
+	})
+}
+
+// Listen opens the listener described by Addr
+func (s *Server) Listen() (net.Listener, error) {
+	switch {
+	case strings.HasPrefix(s.Addr, "unix:"):
+		path := strings.TrimPrefix(s.Addr, "unix:")
+		// Remove the socket left by a previous run, but never a file at a mistyped address, nor the socket of a
+		// running instance: only sockets refusing connections are stale
+		info, err := os.Lstat(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return nil, Wrap(err, "error checking stale socket")
+		case info.Mode()&os.ModeSocket == 0:
+			return nil, fmt.Errorf("%w: %s is not a socket", ErrNotSocket, path)
+		default:
+			conn, err := net.Dial("unix", path)
+			if err == nil {
+				_ = conn.Close()
+				return nil, fmt.Errorf("%w: %s", ErrSocketInUse, path)
+			}
+			if !errors.Is(err, syscall.ECONNREFUSED) {
+				return nil, Wrap(err, "error checking stale socket")
+			}
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return nil, Wrap(err, "error removing stale socket")
+			}
+		}
+		listener, err := net.Listen("unix", path)
+		if err != nil {
+			return nil, Wrap(err, "error listening on unix socket")
+		}
+		return listener, nil
+	case strings.HasPrefix(s.Addr, "fd:"):
+		fd, err := strconv.Atoi(strings.TrimPrefix(s.Addr, "fd:"))
+		if err != nil {
+			return nil, fmt.Errorf("%w: bad file descriptor %q", ErrNoInheritedListener, s.Addr)
+		}
+		return listenFD(fd)
+	case s.Addr == "systemd":
+		if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) || os.Getenv("LISTEN_FDS") == "" {
+			return nil, fmt.Errorf("%w: LISTEN_PID and LISTEN_FDS are not set for this process", ErrNoInheritedListener)
+		}
+		return listenFD(systemdFirstFD)
+	default:
+		listener, err := net.Listen("tcp", s.Addr)
+		if err != nil {
+			return nil, Wrap(err, "error listening")
+		}
+		return listener, nil
+	}
+}
+
+func listenFD(fd int) (net.Listener, error) {
+	file := os.NewFile(uintptr(fd), "listener")
+	if file == nil {
+		return nil, fmt.Errorf("%w: invalid file descriptor %d", ErrNoInheritedListener, fd)
+	}
+	defer file.Close() //nolint:errcheck
+	listener, err := net.FileListener(file)
+	if err != nil {
+		return nil, Wrap(err, "error listening on inherited file descriptor")
+	}
+	return listener, nil
+}
+
+// Run listens on Addr and serves until the context is cancelled or SIGINT/SIGTERM is received
+func (s *Server) Run(ctx context.Context) error {
+	listener, err := s.Listen()
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	return s.Serve(ctx, listener)
+}
+
+// Serve serves on the listener until the context is cancelled, then drains and shuts down
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	mux := http.NewServeMux()
+	// An empty path disables its endpoint
+	endpoints := []struct {
+		path    string
+		handler http.Handler
+	}{
+		{s.LivenessPath, s.LivenessHandler()},
+		{s.ReadinessPath, s.ReadinessHandler()},
+		{s.HealthPath, s.Checks.Handler()},
+		{s.VersionPath, VersionHandler()},
+	}
+	paths := map[string]bool{}
+	for _, endpoint := range endpoints {
+		if endpoint.path == "" {
+			continue
+		}
+		if paths[endpoint.path] {
+			return errors.Join(fmt.Errorf("%w: %s", ErrDuplicatePath, endpoint.path), listener.Close())
+		}
+		paths[endpoint.path] = true
+		mux.Handle("GET "+endpoint.path, endpoint.handler)
+	}
+	mux.Handle("/", s.Handler)
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: s.ReadHeaderTimeout,
+		ReadTimeout:       s.ReadTimeout,
+		WriteTimeout:      s.WriteTimeout,
+		IdleTimeout:       s.IdleTimeout,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("server listening", slog.String("addr", listener.Addr().String()))
+		serveErr <- server.Serve(listener)
+	}()
+	select {
+	case err := <-serveErr:
+		return errors.Join(Wrap(err, "error serving"), s.shutdown())
+	case <-ctx.Done():
+	}
+
+	slog.Info("server draining")
+	s.draining.Store(true)
+	time.Sleep(s.DrainDelay)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.ShutdownTimeout)
+	defer cancel()
+	var errs []error
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		errs = append(errs, Wrap(err, "error draining connections"), server.Close())
+	}
+	errs = append(errs, s.shutdown())
+	slog.Info("server stopped")
+	return errors.Join(errs...)
+}
+
+func (s *Server) shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.ShutdownTimeout)
+	defer cancel()
+	s.mutex.Lock()
+	hooks := s.hooks
+	s.mutex.Unlock()
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i].hook(ctx); err != nil {
+			errs = append(errs, Wrap(err, "error in shutdown hook "+hooks[i].name))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,215 @@
+package kcore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func get(t *testing.T, client *http.Client, url string) (int, string) {
+	t.Helper()
+	response, err := client.Get(url)
+	require.NoError(t, err)
+	defer response.Body.Close() //nolint:errcheck
+	body, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	return response.StatusCode, string(body)
+}
+
+func startServer(t *testing.T, server *Server, listener net.Listener) (context.CancelFunc, chan error) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- server.Serve(ctx, listener) }()
+	return cancel, done
+}
+
+func TestServer_serveAndShutdown(t *testing.T) {
+	server := NewServer("127.0.0.1:0", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello"))
+	}))
+	shutdown := []string{}
+	server.OnShutdown("database", func(ctx context.Context) error {
+		shutdown = append(shutdown, "database")
+		return nil
+	})
+	server.OnShutdown("jobs", func(ctx context.Context) error {
+		shutdown = append(shutdown, "jobs")
+		return errors.New("workers still running")
+	})
+	ready := true
+	server.AddReadinessCheck("database", func(ctx context.Context) error {
+		if !ready {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	listener, err := server.Listen()
+	require.NoError(t, err)
+	cancel, done := startServer(t, server, listener)
+	url := "http://" + listener.Addr().String()
+
+	code, body := get(t, http.DefaultClient, url+"/")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "hello", body)
+	code, _ = get(t, http.DefaultClient, url+"/livez")
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = get(t, http.DefaultClient, url+"/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	ready = false
+	code, body = get(t, http.DefaultClient, url+"/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "database is not ready\n", body)
+
+	cancel()
+	err = <-done
+	assert.ErrorContains(t, err, "error in shutdown hook jobs: workers still running")
+	assert.Equal(t, []string{"jobs", "database"}, shutdown)
+}
+
+func TestServer_drainDelay(t *testing.T) {
+	server := NewServer("127.0.0.1:0", http.NotFoundHandler())
+	server.DrainDelay = 200 * time.Millisecond
+	listener, err := server.Listen()
+	require.NoError(t, err)
+	cancel, done := startServer(t, server, listener)
+
+	cancel()
+	require.Eventually(t, server.draining.Load, time.Second, time.Millisecond)
+	code, body := get(t, http.DefaultClient, "http://"+listener.Addr().String()+"/readyz")
+
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "draining\n", body)
+	require.NoError(t, <-done)
+}
+
+func TestServer_disabledEndpoints(t *testing.T) {
+	server := NewServer("127.0.0.1:0", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("app " + r.URL.Path))
+	}))
+	// The health report and build info are opt-in
+	listener, err := server.Listen()
+	require.NoError(t, err)
+	cancel, done := startServer(t, server, listener)
+	url := "http://" + listener.Addr().String()
+
+	_, body := get(t, http.DefaultClient, url+"/healthz")
+	assert.Equal(t, "app /healthz", body)
+	_, body = get(t, http.DefaultClient, url+"/version")
+	assert.Equal(t, "app /version", body)
+	code, _ := get(t, http.DefaultClient, url+"/livez")
+	assert.Equal(t, http.StatusOK, code)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestServer_unixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.sock")
+	server := NewServer("unix:"+path, http.NotFoundHandler())
+	listener, err := server.Listen()
+	require.NoError(t, err)
+	// A stale socket, left by a crashed run, is replaced
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, listener.Close())
+	listener, err = server.Listen()
+	require.NoError(t, err)
+	cancel, done := startServer(t, server, listener)
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+
+	code, _ := get(t, client, "http://app/livez")
+
+	assert.Equal(t, http.StatusOK, code)
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestServer_unixSocketInUse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.sock")
+	running, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	defer running.Close() //nolint:errcheck
+
+	_, err = NewServer("unix:"+path, http.NotFoundHandler()).Listen()
+
+	assert.ErrorIs(t, err, ErrSocketInUse)
+	conn, err := net.Dial("unix", path)
+	require.NoError(t, err, "the socket of the running server is kept")
+	require.NoError(t, conn.Close())
+}
+
+func TestServer_unixSocketOverFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.db")
+	require.NoError(t, os.WriteFile(path, []byte("data"), 0o600))
+	server := NewServer("unix:"+path, http.NotFoundHandler())
+
+	_, err := server.Listen()
+
+	assert.ErrorIs(t, err, ErrNotSocket)
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(content))
+}
+
+func TestServer_inheritedFileDescriptor(t *testing.T) {
+	parent, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	file, err := parent.(*net.TCPListener).File()
+	require.NoError(t, err)
+	require.NoError(t, parent.Close())
+	server := NewServer("fd:"+strconv.Itoa(int(file.Fd())), http.NotFoundHandler())
+
+	listener, err := server.Listen()
+
+	require.NoError(t, err)
+	assert.Equal(t, parent.Addr().String(), listener.Addr().String())
+	require.NoError(t, listener.Close())
+
+	_, err = NewServer("fd:x", nil).Listen()
+	assert.ErrorIs(t, err, ErrNoInheritedListener)
+	_, err = NewServer("systemd", nil).Listen()
+	assert.ErrorIs(t, err, ErrNoInheritedListener)
+}
+
+func TestServer_healthEndpoints(t *testing.T) {
+	server := NewServer("127.0.0.1:0", http.NotFoundHandler())
+	server.HealthPath, server.VersionPath = "/healthz", "/version"
+	listener, err := server.Listen()
+	require.NoError(t, err)
+	cancel, done := startServer(t, server, listener)
+	url := "http://" + listener.Addr().String()
+
+	code, _ := get(t, http.DefaultClient, url+"/healthz")
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = get(t, http.DefaultClient, url+"/version")
+	assert.Equal(t, http.StatusOK, code)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestServer_duplicatePath(t *testing.T) {
+	server := NewServer("127.0.0.1:0", http.NotFoundHandler())
+	server.HealthPath = "/readyz"
+	listener, err := server.Listen()
+	require.NoError(t, err)
+
+	err = server.Serve(context.Background(), listener)
+
+	assert.ErrorIs(t, err, ErrDuplicatePath)
+	assert.ErrorContains(t, err, "/readyz")
+}
@@ -0,0 +1,48 @@
+package kcore
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// SQLDialect adapts the queries of the SQL backed stores to the database driver
+type SQLDialect int
+
+const (
+	// SQLite and MySQL style ? placeholders
+	SQLite SQLDialect = iota
+	// Postgres style $1 placeholders
+	Postgres
+)
+
+func (d SQLDialect) rebind(query string) string {
+	if d != Postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, char := range query {
+		if char == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(char)
+	}
+	return b.String()
+}
+
+// isUniqueViolation reports whether err is a unique constraint violation, from the error code of the driver:
+// the SQLSTATE of Postgres drivers (pgx, lib/pq), or the extended result code of modernc.org/sqlite
+func (d SQLDialect) isUniqueViolation(err error) bool {
+	switch d {
+	case Postgres:
+		var state interface{ SQLState() string }
+		return errors.As(err, &state) && state.SQLState() == "23505"
+	default:
+		var code interface{ Code() int }
+		// SQLITE_CONSTRAINT_UNIQUE and SQLITE_CONSTRAINT_PRIMARYKEY
+		return errors.As(err, &code) && (code.Code() == 2067 || code.Code() == 1555)
+	}
+}
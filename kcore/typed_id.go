@@ -0,0 +1,92 @@
+package kcore
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrWrongIDPrefix = errors.New("wrong id prefix")
+
+// An entity type can implement IDPrefixer so that its TypedID is displayed with a readable prefix, like usr_AYsq…
+type IDPrefixer interface {
+	IDPrefix() string
+}
+
+// TypedID is an ID bound to the entity T, so that IDs of different entities can't be mixed up.
+// It has the same encodings as ID, with the optional prefix of T in its text form. The database form has no prefix.
+//
+//	type User struct{ ... }
+//	func (User) IDPrefix() string { return "usr" }
+//	type UserID = kcore.TypedID[User]
+type TypedID[T any] struct {
+	ID
+}
+
+func idPrefix[T any]() string {
+	var entity T
+	if prefixer, ok := any(entity).(IDPrefixer); ok {
+		return prefixer.IDPrefix()
+	}
+	return ""
+}
+
+func NewTypedID[T any]() TypedID[T] {
+	return TypedID[T]{NewID()}
+}
+
+// TypedIDFrom converts an existing ID, the opposite conversion is the ID field
+func TypedIDFrom[T any](id ID) TypedID[T] {
+	return TypedID[T]{id}
+}
+
+func (id TypedID[T]) String() string {
+	if prefix := idPrefix[T](); prefix != "" {
+		return prefix + "_" + id.ID.String()
+	}
+	return id.ID.String()
+}
+
+// Format prints the prefixed String form, like ID.Format
+func (id TypedID[T]) Format(f fmt.State, verb rune) {
+	_, _ = fmt.Fprintf(f, fmt.FormatString(f, verb), id.String())
+}
+
+// ParseTypedID parses the prefixed form returned by String, and rejects IDs of another entity or without the prefix
+// of T. The unprefixed forms of ParseID are accepted only for T without prefix.
+func ParseTypedID[T any](value string) (TypedID[T], error) {
+	if prefix := idPrefix[T](); prefix != "" {
+		raw, ok := strings.CutPrefix(value, prefix+"_")
+		if !ok {
+			return TypedID[T]{}, fmt.Errorf("%w: expected %q", ErrWrongIDPrefix, prefix)
+		}
+		value = raw
+	}
+	id, err := ParseID(value)
+	if err != nil {
+		return TypedID[T]{}, err
+	}
+	return TypedID[T]{id}, nil
+}
+
+// ParseLegacyTypedID is ParseTypedID that also accepts the unprefixed forms of ParseID, for URLs created before T
+// had a prefix
+func ParseLegacyTypedID[T any](value string) (TypedID[T], error) {
+	if id, err := ParseID(value); err == nil {
+		return TypedID[T]{id}, nil
+	}
+	return ParseTypedID[T](value)
+}
+
+func (id TypedID[T]) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+func (id *TypedID[T]) UnmarshalText(text []byte) error {
+	parsed, err := ParseTypedID[T](string(text))
+	if err != nil {
+		return err
+	}
+	*id = parsed
+	return nil
+}
@@ -0,0 +1,113 @@
+package kcore
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testUser struct{}
+
+func (testUser) IDPrefix() string { return "usr" }
+
+type testOrder struct{}
+
+func (testOrder) IDPrefix() string { return "ord" }
+
+type testNote struct{}
+
+func TestTypedID_String(t *testing.T) {
+	id := NewTypedID[testUser]()
+
+	assert.Equal(t, "usr_"+id.ID.String(), id.String())
+	assert.Equal(t, id.ID.String(), TypedIDFrom[testNote](id.ID).String())
+}
+
+func TestTypedID_Format(t *testing.T) {
+	id := NewTypedID[testUser]()
+
+	assert.Equal(t, id.String(), fmt.Sprint(id))
+	assert.Equal(t, id.String(), fmt.Sprintf("%v", id))
+}
+
+func TestParseTypedID(t *testing.T) {
+	id := NewTypedID[testUser]()
+
+	parsed, err := ParseTypedID[testUser](id.String())
+	require.NoError(t, err)
+	assert.Equal(t, id, parsed)
+
+	note := NewTypedID[testNote]()
+	parsedNote, err := ParseTypedID[testNote](note.String())
+	require.NoError(t, err)
+	assert.Equal(t, note, parsedNote)
+}
+
+func TestParseTypedID_wrongPrefix(t *testing.T) {
+	id := NewTypedID[testOrder]()
+
+	_, err := ParseTypedID[testUser](id.String())
+	assert.ErrorIs(t, err, ErrWrongIDPrefix)
+
+	_, err = ParseTypedID[testUser](id.ID.String())
+	assert.ErrorIs(t, err, ErrWrongIDPrefix, "the prefix of T is required")
+	_, err = ParseTypedID[testUser](id.ID.UUID.String())
+	assert.ErrorIs(t, err, ErrWrongIDPrefix, "the prefix of T is required")
+}
+
+func TestParseLegacyTypedID(t *testing.T) {
+	id := NewTypedID[testUser]()
+	for _, value := range []string{id.String(), id.ID.String(), id.ID.UUID.String()} {
+		parsed, err := ParseLegacyTypedID[testUser](value)
+		require.NoError(t, err)
+		assert.Equal(t, id, parsed)
+	}
+
+	_, err := ParseLegacyTypedID[testUser](TypedIDFrom[testOrder](id.ID).String())
+	assert.ErrorIs(t, err, ErrWrongIDPrefix)
+}
+
+func TestTypedID_JSON(t *testing.T) {
+	type order struct {
+		ID    TypedID[testOrder] `json:"id"`
+		Buyer TypedID[testUser]  `json:"buyer"`
+	}
+	original := order{ID: NewTypedID[testOrder](), Buyer: NewTypedID[testUser]()}
+
+	content, err := json.Marshal(original)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id":"`+original.ID.String()+`","buyer":"`+original.Buyer.String()+`"}`, string(content))
+
+	var decoded order
+	require.NoError(t, json.Unmarshal(content, &decoded))
+	assert.Equal(t, original, decoded)
+
+	assert.ErrorIs(t, json.Unmarshal([]byte(`{"buyer":"`+original.ID.String()+`"}`), &decoded), ErrWrongIDPrefix)
+}
+
+func TestTypedID_Scan(t *testing.T) {
+	id := NewTypedID[testUser]()
+	value, err := id.Value()
+	require.NoError(t, err)
+
+	var scanned TypedID[testUser]
+	require.NoError(t, scanned.Scan(value))
+
+	assert.Equal(t, id, scanned)
+}
+
+func TestTypedID_form(t *testing.T) {
+	buyer := NewTypedID[testUser]()
+	var form struct {
+		Buyer TypedID[testUser] `form:"buyer"`
+	}
+
+	err := DecodeForm(url.Values{"buyer": {buyer.String()}}, nil, &form)
+
+	require.NoError(t, err)
+	assert.Equal(t, buyer, form.Buyer)
+}
@@ -0,0 +1,163 @@
+package ki18n
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A translation is the text of a key, or its CLDR plural forms when it depends on a count:
+//
+//	"%d items":
+//	  one: "%d article"
+//	  other: "%d articles"
+type translation struct {
+	text   string
+	plural map[string]string
+}
+
+// DefaultNamespace is the locale file of keys translated without a namespace: <lang>/index.yml
+const DefaultNamespace = "index"
+
+// catalog holds the translations by language, then namespace, then key
+type catalog map[string]map[string]map[string]translation
+
+// MessageKey qualifies a key with its message context, with the EOT separator of gettext, as keys are stored in the
+// catalog and checked by gettext
+func MessageKey(msgctxt string, key string) string {
+	if msgctxt == "" {
+		return key
+	}
+	return msgctxt + "\x04" + key
+}
+
+// SplitMessageKey returns the message context and the key of a MessageKey
+func SplitMessageKey(messageKey string) (string, string) {
+	if msgctxt, key, ok := strings.Cut(messageKey, "\x04"); ok {
+		return msgctxt, key
+	}
+	return "", messageKey
+}
+
+// loadCatalog reads the <lang>/<namespace>.yml files of the registered languages, if localesFS is not nil
+func loadCatalog(localesFS fs.FS, langs []string) (catalog, error) {
+	c := make(catalog, len(langs))
+	for _, lang := range langs {
+		c[lang] = map[string]map[string]translation{}
+	}
+	if localesFS == nil {
+		return c, nil
+	}
+	files, err := fs.Glob(localesFS, "*/*.yml")
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		namespaces, ok := c[path.Dir(file)]
+		if !ok {
+			continue
+		}
+		content, err := fs.ReadFile(localesFS, file)
+		if err != nil {
+			return nil, err
+		}
+		translations := map[string]translation{}
+		if err := parseTranslations(content, translations); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		namespaces[strings.TrimSuffix(path.Base(file), ".yml")] = translations
+	}
+	return c, nil
+}
+
+// parseTranslations reads a mapping of keys to translations, where "@" keys are message contexts with their own keys,
+// and other nested mappings are flattened into dotted keys:
+//
+//	Close: Fermer
+//	"@adjective":
+//	  Close: Proche
+//	menu:
+//	  home: Accueil
+func parseTranslations(content []byte, translations map[string]translation) error {
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return err
+	}
+	if len(document.Content) == 0 {
+		return nil
+	}
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: expected a mapping of keys to translations", root.Line)
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i].Value, root.Content[i+1]
+		msgctxt, isContext := strings.CutPrefix(key, "@")
+		if !isContext || value.Kind != yaml.MappingNode {
+			if err := parseTranslation(key, value, translations); err != nil {
+				return err
+			}
+			continue
+		}
+		for j := 0; j+1 < len(value.Content); j += 2 {
+			key := MessageKey(msgctxt, value.Content[j].Value)
+			if err := parseTranslation(key, value.Content[j+1], translations); err != nil {
+				return fmt.Errorf("context %q: %w", msgctxt, err)
+			}
+		}
+	}
+	return nil
+}
+
+func parseTranslation(key string, value *yaml.Node, translations map[string]translation) error {
+	switch value.Kind {
+	case yaml.ScalarNode:
+		translations[key] = translation{text: value.Value}
+	case yaml.MappingNode:
+		if !isPluralForms(value) {
+			// Nested keys are flattened, like "menu.home" for home under menu
+			for i := 0; i+1 < len(value.Content); i += 2 {
+				if err := parseTranslation(key+"."+value.Content[i].Value, value.Content[i+1], translations); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		plural, err := parsePluralForms(value)
+		if err != nil {
+			_, key := SplitMessageKey(key)
+			return fmt.Errorf("key %q: %w", key, err)
+		}
+		translations[key] = translation{text: plural["other"], plural: plural}
+	default:
+		_, key := SplitMessageKey(key)
+		return fmt.Errorf("key %q: expected a string, plural forms or nested keys", key)
+	}
+	return nil
+}
+
+// isPluralForms reports whether all the keys of a mapping are CLDR plural categories, other mappings are nested keys
+func isPluralForms(node *yaml.Node) bool {
+	for i := 0; i < len(node.Content); i += 2 {
+		if !slices.Contains(cldrCategories, node.Content[i].Value) {
+			return false
+		}
+	}
+	return len(node.Content) > 0
+}
+
+func parsePluralForms(node *yaml.Node) (map[string]string, error) {
+	plural := map[string]string{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		category, form := node.Content[i].Value, node.Content[i+1]
+		if form.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("plural category %q: expected a string", category)
+		}
+		plural[category] = form.Value
+	}
+	return plural, nil
+}
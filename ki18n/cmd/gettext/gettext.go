@@ -12,10 +12,12 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 
 	"github.com/martinlehoux/kagamigo/kcore"
+	"github.com/martinlehoux/kagamigo/ki18n"
 	"golang.org/x/exp/slog"
 	"gopkg.in/yaml.v3"
 )
@@ -84,35 +86,210 @@ func (p ArgsParser) ArgsCount() int {
 	return p.argsCount
 }
 
-func extractKeys(content string) map[string]int {
-	extractedKeys := make(map[string]int, 0)
-	// Match both { ... } style and @ki18n.Tr(...) style, supporting both Tr and Str functions
-	reg := regexp.MustCompile(`(?:\{ ?((?:login\.|ki18n\.)?(?:Tr|Str)\([^}]*\) ?)\}|@ki18n\.Tr\(([^)]+)\))`)
-	matches := reg.FindAllStringSubmatch(content, -1)
-	for _, match := range matches {
-		var trCall string
-		if match[1] != "" {
+// An extractedKey is a key found in templates, with the number of format args of its calls
+type extractedKey struct {
+	Args int
+	// Plural keys are translated with TrN, and need the plural categories of each language
+	Plural bool
+	// MessageFormat keys are called with a single ki18n.Args, and formatted as ICU messages
+	MessageFormat bool
+}
+
+// closingParen returns the index of the parenthesis closing the call args starting at start, skipping string literals
+func closingParen(content string, start int) int {
+	nesting := 0
+	for i := start; i < len(content); i++ {
+		switch content[i] {
+		case '"', '`', '\'':
+			quote := content[i]
+			for i++; i < len(content) && content[i] != quote; i++ {
+				if content[i] == '\\' && quote != '`' {
+					i++
+				}
+			}
+		case '(':
+			nesting++
+		case ')':
+			if nesting == 0 {
+				return i
+			}
+			nesting--
+		}
+	}
+	return -1
+}
+
+// A scope is the namespace and message context of Tr calls, from ki18n.Namespace and ki18n.Context
+type scope struct {
+	namespace string
+	msgctxt   string
+}
+
+// scopeChain matches the Namespace and Context calls of a scope, with args of at most one nested call
+const scopeChain = `(?:(?:Namespace|Context)\((?:[^()]|\([^()]*\))*\)\.)*`
+
+var (
+	// Match both { ... } style and @ki18n.Tr(...) style, supporting Tr, TrN, TrHTML and Str functions of ki18n, of
+	// scopes and of scope variables. The args are read up to the closing parenthesis, as ICU keys and ki18n.Args
+	// contain braces.
+	trCallRegexp = regexp.MustCompile(`\{ ?((?:\w+\.)?` + scopeChain + `(?:TrN|TrHTML|Tr|Str))\(|@(\w+\.` + scopeChain + `(?:TrN|TrHTML|Tr))\(`)
+	// Scope variables, such as billing := ki18n.Namespace("billing")
+	scopeVarRegexp = regexp.MustCompile(`(\w+)\s*:?=\s*(ki18n\.(?:Namespace|Context)\("[^"]*"\)(?:\.(?:Namespace|Context)\("[^"]*"\))*)`)
+)
+
+// extractScopes returns the scope variables declared with literal Namespace and Context calls, by name
+func extractScopes(content string) map[string]scope {
+	scopes := make(map[string]scope, 0)
+	for _, match := range scopeVarRegexp.FindAllStringSubmatch(content, -1) {
+		expr, err := parser.ParseExpr(match[2])
+		if err != nil {
+			continue
+		}
+		if s, ok := exprScope(expr, nil); ok {
+			scopes[match[1]] = s
+		}
+	}
+	return scopes
+}
+
+// extractKeys returns the keys of Tr calls by namespace, qualified with their message context. Calls of scopes that
+// are not literal, or of unknown scope variables, are skipped with a warning.
+func extractKeys(content string, scopes map[string]scope) map[string]map[string]extractedKey {
+	extractedKeys := make(map[string]map[string]extractedKey, 0)
+	for _, match := range trCallRegexp.FindAllStringSubmatchIndex(content, -1) {
+		end := closingParen(content, match[1])
+		if end < 0 {
+			continue
+		}
+		var function string
+		if match[2] >= 0 {
 			// { ... } style match
-			trCall = match[1]
+			function = content[match[2]:match[3]]
 		} else {
 			// @ki18n.Tr(...) style match
-			trCall = "ki18n.Tr(" + match[2] + ")"
-		}
-		call, isTrCall := parseTrCall(trCall)
-		if isTrCall {
-			keyLiteral := call.Args[1].(*ast.BasicLit).Value
-			key, err := strconv.Unquote(keyLiteral)
-			if err != nil {
-				slog.Warn("error unquoting key", "key", keyLiteral)
-				key = strings.Trim(keyLiteral, `"`)
-			}
-			extractedKeys[key] = len(call.Args) - 2
+			function = content[match[4]:match[5]]
+		}
+		call, name, isTrCall := parseTrCall(function + content[match[1]-1:end+1])
+		if !isTrCall || len(call.Args) < 2 {
+			continue
+		}
+		keyLiteral, ok := call.Args[1].(*ast.BasicLit)
+		if !ok {
+			slog.Warn("skipping call with a key that is not literal", slog.String("call", function))
+			continue
+		}
+		key, err := strconv.Unquote(keyLiteral.Value)
+		if err != nil {
+			slog.Warn("error unquoting key", "key", keyLiteral.Value)
+			key = strings.Trim(keyLiteral.Value, `"`)
+		}
+		s, ok := callScope(call, scopes)
+		if !ok {
+			slog.Warn("skipping call with a scope that is not literal", slog.String("call", function), slog.String("key", key))
+			continue
+		}
+		if _, ok := extractedKeys[s.namespace]; !ok {
+			extractedKeys[s.namespace] = make(map[string]extractedKey, 0)
+		}
+		// The count of TrN is the first format arg
+		extractedKeys[s.namespace][ki18n.MessageKey(s.msgctxt, key)] = extractedKey{
+			Args:          len(call.Args) - 2,
+			Plural:        name == "TrN",
+			MessageFormat: name != "TrN" && len(call.Args) == 3 && (isArgsLiteral(call.Args[2]) || hasNamedArgs(key)),
 		}
 	}
 	return extractedKeys
 }
 
-func parseTrCall(trCall string) (*ast.CallExpr, bool) {
+// callScope returns the scope of a Tr call, in the default namespace without Namespace call
+func callScope(call *ast.CallExpr, scopes map[string]scope) (scope, bool) {
+	s, ok := scope{}, true
+	if selector, isSelector := call.Fun.(*ast.SelectorExpr); isSelector {
+		s, ok = exprScope(selector.X, scopes)
+	}
+	if s.namespace == "" {
+		s.namespace = ki18n.DefaultNamespace
+	}
+	return s, ok
+}
+
+// exprScope evaluates the Namespace and Context calls of a scope expression with literal args, starting from the
+// ki18n package or a scope variable. The last call of the chain wins, as in ki18n.
+func exprScope(expr ast.Expr, scopes map[string]scope) (scope, bool) {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		if s, ok := scopes[expr.Name]; ok {
+			return s, true
+		}
+		return scope{}, expr.Name == "ki18n" || expr.Name == "login"
+	case *ast.CallExpr:
+		var s scope
+		var name string
+		switch fun := expr.Fun.(type) {
+		case *ast.SelectorExpr:
+			base, ok := exprScope(fun.X, scopes)
+			if !ok {
+				return scope{}, false
+			}
+			s, name = base, fun.Sel.Name
+		case *ast.Ident:
+			name = fun.Name
+		}
+		value, ok := stringArg(expr)
+		if !ok {
+			return scope{}, false
+		}
+		switch name {
+		case "Namespace":
+			s.namespace = value
+		case "Context":
+			s.msgctxt = value
+		default:
+			return scope{}, false
+		}
+		return s, true
+	}
+	return scope{}, false
+}
+
+func isArgsLiteral(expr ast.Expr) bool {
+	literal, ok := expr.(*ast.CompositeLit)
+	if !ok {
+		return false
+	}
+	switch typ := literal.Type.(type) {
+	case *ast.SelectorExpr:
+		return typ.Sel.Name == "Args"
+	case *ast.Ident:
+		return typ.Name == "Args"
+	}
+	return false
+}
+
+// hasNamedArgs reports whether a key is an ICU message with named args and no printf verbs, for calls with an Args
+// variable
+func hasNamedArgs(key string) bool {
+	args, err := ki18n.MessageArgs(key)
+	return err == nil && len(args) > 0 && len(ki18n.PrintfArgs(key)) == 0
+}
+
+func stringArg(call *ast.CallExpr) (string, bool) {
+	if len(call.Args) != 1 {
+		return "", false
+	}
+	literal, ok := call.Args[0].(*ast.BasicLit)
+	if !ok {
+		return "", false
+	}
+	value, err := strconv.Unquote(literal.Value)
+	return value, err == nil
+}
+
+func isTrFunc(name string) bool {
+	return name == "Tr" || name == "TrN" || name == "TrHTML" || name == "Str"
+}
+
+func parseTrCall(trCall string) (*ast.CallExpr, string, bool) {
 	fakePackage := fmt.Sprintf("package main\nfunc main() {\n	%s\n}", trCall)
 	fset := token.NewFileSet()
 	f, err := parser.ParseFile(fset, "", fakePackage, 0)
@@ -122,23 +299,40 @@ func parseTrCall(trCall string) (*ast.CallExpr, bool) {
 	switch call.Fun.(type) {
 	case *ast.SelectorExpr:
 		selector := call.Fun.(*ast.SelectorExpr)
-		return call, selector.Sel.Name == "Tr" || selector.Sel.Name == "Str"
+		return call, selector.Sel.Name, isTrFunc(selector.Sel.Name)
 
 	case *ast.Ident:
 		ident := call.Fun.(*ast.Ident)
-		return call, ident.Name == "Tr" || ident.Name == "Str"
+		return call, ident.Name, isTrFunc(ident.Name)
 	}
-	return call, false
+	return call, "", false
 }
 
-func extractAllKeys() map[string]int {
-	extractedKeys := make(map[string]int, 0)
+func extractAllKeys() map[string]map[string]extractedKey {
+	extractedKeys := make(map[string]map[string]extractedKey, 0)
 
+	// Scope variables may be declared in Go files and used in templates
+	scopes := make(map[string]scope, 0)
 	err := filepath.Walk(".", func(path string, info fs.FileInfo, err error) error {
+		if !info.IsDir() && (filepath.Ext(path) == ".templ" || filepath.Ext(path) == ".go") {
+			content, err := os.ReadFile(path) // #nosec G304
+			kcore.Expect(err, "error reading file")
+			maps.Copy(scopes, extractScopes(string(content)))
+		}
+		return nil
+	})
+	kcore.Expect(err, "error walking templates directory")
+
+	err = filepath.Walk(".", func(path string, info fs.FileInfo, err error) error {
 		if !info.IsDir() && filepath.Ext(path) == ".templ" {
 			content, err := os.ReadFile(path) // #nosec G304
 			kcore.Expect(err, "error reading file")
-			maps.Copy(extractedKeys, extractKeys(string(content)))
+			for namespace, keys := range extractKeys(string(content), scopes) {
+				if _, ok := extractedKeys[namespace]; !ok {
+					extractedKeys[namespace] = make(map[string]extractedKey, 0)
+				}
+				maps.Copy(extractedKeys[namespace], keys)
+			}
 		}
 		return nil
 	})
@@ -147,22 +341,201 @@ func extractAllKeys() map[string]int {
 	return extractedKeys
 }
 
-func getOrCreateLocale(lang string, logger *slog.Logger) map[string]string {
-	currentLocales := make(map[string]string, 0)
-	locales, err := os.ReadFile(filepath.Join("locales", lang, "index.yml")) // #nosec G304
+func getOrCreateLocale(lang string, namespace string, logger *slog.Logger) map[string]any {
+	currentLocales := make(map[string]any, 0)
+	file := filepath.Join("locales", lang, namespace+".yml")
+	locales, err := os.ReadFile(file) // #nosec G304
 	if errors.Is(err, os.ErrNotExist) {
 		logger.Info("no locales file found, creating")
 		err = os.MkdirAll(filepath.Join("locales", lang), 0o700)
 		kcore.Expect(err, "error creating directory")
-		err = os.WriteFile(filepath.Join("locales", lang, "index.yml"), []byte{}, 0o600)
+		err = os.WriteFile(file, []byte{}, 0o600)
 		kcore.Expect(err, "error writing file")
-		locales, err = os.ReadFile(filepath.Join("locales", lang, "index.yml")) // #nosec G304
+		locales, err = os.ReadFile(file) // #nosec G304
 		kcore.Expect(err, "error reading file")
 	} else {
 		kcore.Expect(err, "error reading file")
 	}
 	kcore.Expect(yaml.Unmarshal(locales, &currentLocales), "error unmarshalling yaml")
-	return currentLocales
+	return flattenContexts(currentLocales)
+}
+
+// flattenContexts moves the keys of "@" message context blocks to the top level, qualified with their context
+func flattenContexts(locales map[string]any) map[string]any {
+	flat := make(map[string]any, len(locales))
+	for key, value := range locales {
+		msgctxt, isContext := strings.CutPrefix(key, "@")
+		block, isBlock := value.(map[string]any)
+		if !isContext || !isBlock {
+			flat[key] = value
+			continue
+		}
+		for key, value := range block {
+			flat[ki18n.MessageKey(msgctxt, key)] = value
+		}
+	}
+	return flat
+}
+
+// nestContexts groups the keys qualified with a message context in "@" blocks, for writing
+func nestContexts(locales map[string]any) map[string]any {
+	nested := make(map[string]any, len(locales))
+	for key, value := range locales {
+		msgctxt, text := ki18n.SplitMessageKey(key)
+		if msgctxt == "" {
+			nested[key] = value
+			continue
+		}
+		block, _ := nested["@"+msgctxt].(map[string]any)
+		if block == nil {
+			block = make(map[string]any, 0)
+			nested["@"+msgctxt] = block
+		}
+		block[text] = value
+	}
+	return nested
+}
+
+// localeNamespaces returns the namespaces of the extracted keys, with the default namespace first. Locale files of
+// other namespaces are left untouched with a warning, as their keys may be used through scopes that are not literal.
+func localeNamespaces(langs []string, extractedKeys map[string]map[string]extractedKey, logger *slog.Logger) []string {
+	namespaces := []string{ki18n.DefaultNamespace}
+	for namespace := range extractedKeys {
+		namespaces = append(namespaces, namespace)
+	}
+	slices.Sort(namespaces[1:])
+	namespaces = slices.Compact(namespaces)
+	for _, lang := range langs {
+		files, err := filepath.Glob(filepath.Join("locales", lang, "*.yml"))
+		kcore.Expect(err, "error listing locales files")
+		for _, file := range files {
+			if namespace := strings.TrimSuffix(filepath.Base(file), ".yml"); !slices.Contains(namespaces, namespace) {
+				logger.Warn("skipping namespace without extracted keys", slog.String("lang", lang), slog.String("namespace", namespace))
+			}
+		}
+	}
+	return namespaces
+}
+
+type localeCheck struct {
+	// locales to write: current translations without unused keys, and empty translations to complete
+	locales map[string]any
+	correct int
+	missing bool
+}
+
+// checkArgs checks that a translation uses the same ICU argument names and types as its key for message format keys,
+// or the same printf verbs, where braces are literal text
+func checkArgs(key string, translation string, messageFormat bool, logger *slog.Logger) bool {
+	if translation == "" {
+		return true
+	}
+	if !messageFormat {
+		expected, current := ki18n.PrintfArgs(key), ki18n.PrintfArgs(translation)
+		if !maps.Equal(current, expected) {
+			logger.Info(`found translation with incorrect arguments`, slog.String("key", key), slog.Any("current", current), slog.Any("expected", expected))
+			return false
+		}
+		return true
+	}
+	expected, err := ki18n.MessageArgs(key)
+	if err != nil {
+		logger.Info(`found invalid key`, slog.String("key", key), slog.String("error", err.Error()))
+		return false
+	}
+	current, err := ki18n.MessageArgs(translation)
+	if err != nil {
+		logger.Info(`found invalid translation`, slog.String("key", key), slog.String("error", err.Error()))
+		return false
+	}
+	if !maps.Equal(current, expected) {
+		logger.Info(`found translation with incorrect arguments`, slog.String("key", key), slog.Any("current", current), slog.Any("expected", expected))
+		return false
+	}
+	return true
+}
+
+func emptyPluralForms(lang string) map[string]string {
+	forms := map[string]string{}
+	for _, category := range ki18n.PluralCategories(lang) {
+		forms[category] = ""
+	}
+	return forms
+}
+
+// checkPluralForms checks that every plural category of the language is translated with the expected args
+func checkPluralForms(lang string, key string, translation map[string]any, expected extractedKey, logger *slog.Logger) (map[string]string, bool, bool) {
+	forms := emptyPluralForms(lang)
+	correct, missing := true, false
+	for category, form := range translation {
+		if _, ok := forms[category]; !ok {
+			logger.Info(`found unused plural category`, slog.String("key", key), slog.String("category", category))
+			continue
+		}
+		text, _ := form.(string)
+		if !checkArgs(key, text, expected.MessageFormat, logger.With(slog.String("category", category))) {
+			continue
+		}
+		forms[category] = text
+	}
+	for category, text := range forms {
+		if _, ok := translation[category]; !ok {
+			logger.Info(`found missing plural category`, slog.String("key", key), slog.String("category", category))
+			missing = true
+		}
+		correct = correct && text != ""
+	}
+	return forms, correct, missing
+}
+
+func checkLocale(lang string, currentLocales map[string]any, extractedKeys map[string]extractedKey, logger *slog.Logger) localeCheck {
+	check := localeCheck{locales: make(map[string]any, 0)}
+	for key, current := range currentLocales {
+		expected, ok := extractedKeys[key]
+		if !ok {
+			logger.Info(`found unused key`, slog.String("key", key))
+			continue
+		}
+		if expected.Plural {
+			translation, isPlural := current.(map[string]any)
+			if !isPlural {
+				logger.Info(`found translation without plural forms`, slog.String("key", key))
+				check.locales[key] = emptyPluralForms(lang)
+				check.missing = true
+				continue
+			}
+			forms, correct, missing := checkPluralForms(lang, key, translation, expected, logger)
+			check.locales[key] = forms
+			check.missing = check.missing || missing
+			if correct {
+				check.correct++
+			}
+			continue
+		}
+		translation, _ := current.(string)
+		switch {
+		case !checkArgs(key, translation, expected.MessageFormat, logger):
+			check.locales[key] = ""
+		case translation == "":
+			check.locales[key] = ""
+		default:
+			check.locales[key] = translation
+			check.correct++
+		}
+	}
+
+	for key, expected := range extractedKeys {
+		if _, ok := currentLocales[key]; !ok {
+			logger.Info(`found missing key`, slog.String("key", key))
+			if expected.Plural {
+				check.locales[key] = emptyPluralForms(lang)
+			} else {
+				check.locales[key] = ""
+			}
+			check.missing = true
+		}
+	}
+	return check
 }
 
 func main() {
@@ -172,51 +545,34 @@ func main() {
 	langs := [...]string{"en-GB", "fr-FR"}
 
 	extractedKeys := extractAllKeys()
-	baseLogger.Info("extracted keys from templates", slog.Int("count", len(extractedKeys)))
+	count := 0
+	for _, keys := range extractedKeys {
+		count += len(keys)
+	}
+	baseLogger.Info("extracted keys from templates", slog.Int("count", count), slog.Int("namespaces", len(extractedKeys)))
 
+	namespaces := localeNamespaces(langs[:], extractedKeys, baseLogger)
 	missingKeys := false
 	for _, lang := range langs {
-		logger := baseLogger.With(slog.String("lang", lang))
-		currentLocales := getOrCreateLocale(lang, logger)
-		newLocales := make(map[string]string, 0)
-
-		correctLocales := 0
-		for key, translation := range currentLocales {
-			currentArgsCount := strings.Count(translation, "%")
-			expectedArgsCount, ok := extractedKeys[key]
-			switch {
-			case !ok:
-				logger.Info(`found unused key`, slog.String("key", key))
-			case currentArgsCount != expectedArgsCount:
-				logger.Info(`found translation with incorrect number of arguments`, slog.String("key", key), slog.Int("current", currentArgsCount), slog.Int("expected", expectedArgsCount))
-				newLocales[key] = ""
-			case translation == "":
-				newLocales[key] = ""
-			default:
-				newLocales[key] = currentLocales[key]
-				correctLocales++
-			}
-		}
+		for _, namespace := range namespaces {
+			logger := baseLogger.With(slog.String("lang", lang), slog.String("namespace", namespace))
+			currentLocales := getOrCreateLocale(lang, namespace, logger)
+			check := checkLocale(lang, currentLocales, extractedKeys[namespace], logger)
+			missingKeys = missingKeys || check.missing
 
-		for key := range extractedKeys {
-			if _, ok := currentLocales[key]; !ok {
-				logger.Info(`found missing key`, slog.String("key", key))
-				newLocales[key] = ""
-				missingKeys = true
+			var completion string
+			if len(extractedKeys[namespace]) > 0 {
+				completion = fmt.Sprintf("%d%%", check.correct*100/len(extractedKeys[namespace]))
+			} else {
+				completion = "?%"
 			}
-		}
-		var completion string
-		if len(extractedKeys) > 0 {
-			completion = fmt.Sprintf("%d%%", correctLocales*100/len(extractedKeys))
-		} else {
-			completion = "?%"
-		}
-		logger.Info("finished checking locales", slog.Int("count", len(newLocales)), slog.Int("correct", correctLocales), slog.String("completion", completion))
+			logger.Info("finished checking locales", slog.Int("count", len(check.locales)), slog.Int("correct", check.correct), slog.String("completion", completion))
 
-		if *write {
-			content, err := yaml.Marshal(newLocales)
-			kcore.Expect(err, "error marshalling yaml")
-			kcore.Expect(os.WriteFile(filepath.Join("locales", lang, "index.yml"), content, 0o600), "error writing file")
+			if *write {
+				content, err := yaml.Marshal(nestContexts(check.locales))
+				kcore.Expect(err, "error marshalling yaml")
+				kcore.Expect(os.WriteFile(filepath.Join("locales", lang, namespace+".yml"), content, 0o600), "error writing file")
+			}
 		}
 	}
 
@@ -1,98 +1,300 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/martinlehoux/kagamigo/ki18n"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/exp/slog"
 )
 
 func TestExtractKeys(t *testing.T) {
 	content := `{ login.Tr("fr", "Hello") }`
 
-	keys := extractKeys(content)
+	keys := extractKeys(content, nil)[ki18n.DefaultNamespace]
 
 	assert.Len(t, keys, 1)
-	assert.Equal(t, 0, keys["Hello"])
+	assert.Equal(t, 0, keys["Hello"].Args)
 }
 
 func TestExtractKeysWithoutSpaces(t *testing.T) {
 	content := `{login.Tr("fr", "Hello")}`
 
-	keys := extractKeys(content)
+	keys := extractKeys(content, nil)[ki18n.DefaultNamespace]
 
 	assert.Len(t, keys, 1)
-	assert.Equal(t, 0, keys["Hello"])
+	assert.Equal(t, 0, keys["Hello"].Args)
 }
 
 func TestExtractKeysWithOneArgs(t *testing.T) {
 	content := `{ login.Tr("fr", "approveButton", a.Username) }`
 
-	keys := extractKeys(content)
+	keys := extractKeys(content, nil)[ki18n.DefaultNamespace]
 
 	assert.Len(t, keys, 1)
-	assert.Equal(t, 1, keys["approveButton"])
+	assert.Equal(t, 1, keys["approveButton"].Args)
 }
 
 func TestExtractKeysWithSeveralArgs(t *testing.T) {
 	content := `{ login.Tr("fr", "approveButton", a.Username, a.Age, a.Email) }`
 
-	keys := extractKeys(content)
+	keys := extractKeys(content, nil)[ki18n.DefaultNamespace]
 
 	assert.Len(t, keys, 1)
-	assert.Equal(t, 3, keys["approveButton"])
+	assert.Equal(t, 3, keys["approveButton"].Args)
 }
 
 func TestExtractKeysWithComplexArgs(t *testing.T) {
 	content := `{ login.Tr("fr", "raceStart_chosen", a.StartAt.Format("Monday, January 2, 2006 at 15:04")) }`
 
-	keys := extractKeys(content)
+	keys := extractKeys(content, nil)[ki18n.DefaultNamespace]
 
 	assert.Len(t, keys, 1)
-	assert.Equal(t, 1, keys["raceStart_chosen"])
+	assert.Equal(t, 1, keys["raceStart_chosen"].Args)
 }
 
 func TestExtractMultipleKeys(t *testing.T) {
 	content := `{ login.Tr("fr", "test_1", a.StartAt.Format("Monday, January 2, 2006 at 15:04")) }{ login.Tr("fr", "test_2", a.Test) }`
 
-	keys := extractKeys(content)
+	keys := extractKeys(content, nil)[ki18n.DefaultNamespace]
 
 	assert.Len(t, keys, 2)
-	assert.Equal(t, 1, keys["test_1"])
-	assert.Equal(t, 1, keys["test_2"])
+	assert.Equal(t, 1, keys["test_1"].Args)
+	assert.Equal(t, 1, keys["test_2"].Args)
 }
 
 func TestExtractKeyFromSimpleTrFunc(t *testing.T) {
 	content := `{ Tr("fr", "test") }`
 
-	keys := extractKeys(content)
+	keys := extractKeys(content, nil)[ki18n.DefaultNamespace]
 
 	assert.Len(t, keys, 1)
-	assert.Equal(t, 0, keys["test"])
+	assert.Equal(t, 0, keys["test"].Args)
 }
 
 func TestExtractKeyWithSpan(t *testing.T) {
 	content := `{ Tr("fr", "Hello <span class=\"text-bold\">%s</span>", username) }`
 
-	keys := extractKeys(content)
+	keys := extractKeys(content, nil)[ki18n.DefaultNamespace]
 
 	assert.Len(t, keys, 1)
-	assert.Equal(t, 1, keys["Hello <span class=\"text-bold\">%s</span>"])
+	assert.Equal(t, 1, keys["Hello <span class=\"text-bold\">%s</span>"].Args)
 }
 
 func TestComponent(t *testing.T) {
 	content := `@ki18n.Tr(login.Lng, "Recharge yourself in nature.")`
 
-	keys := extractKeys(content)
+	keys := extractKeys(content, nil)[ki18n.DefaultNamespace]
 
 	assert.Len(t, keys, 1)
-	assert.Equal(t, 0, keys["Recharge yourself in nature."])
+	assert.Equal(t, 0, keys["Recharge yourself in nature."].Args)
 }
 
 func TestStr(t *testing.T) {
 	content := `{ ki18n.Str(login.Lng, "Username") }`
 
-	keys := extractKeys(content)
+	keys := extractKeys(content, nil)[ki18n.DefaultNamespace]
 
 	assert.Len(t, keys, 1)
-	assert.Equal(t, 0, keys["Username"])
+	assert.Equal(t, 0, keys["Username"].Args)
+}
+
+func TestExtractPluralKeys(t *testing.T) {
+	content := `{ ki18n.TrN(ctx, "%d items", len(items)) }@ki18n.TrN(ctx, "%d items in %s", count, folder.Name)`
+
+	keys := extractKeys(content, nil)[ki18n.DefaultNamespace]
+
+	assert.Len(t, keys, 2)
+	assert.Equal(t, extractedKey{Args: 1, Plural: true}, keys["%d items"])
+	assert.Equal(t, extractedKey{Args: 2, Plural: true}, keys["%d items in %s"])
+}
+
+func TestCheckLocale_pluralForms(t *testing.T) {
+	extracted := map[string]extractedKey{
+		"%d items":    {Args: 1, Plural: true},
+		"%d articles": {Args: 1, Plural: true},
+		"%d users":    {Args: 1, Plural: true},
+		"%d files":    {Args: 1, Plural: true},
+	}
+	current := map[string]any{
+		"%d items":    map[string]any{"one": "%d article", "other": "%d articles"},
+		"%d articles": map[string]any{"other": "%d articles"},
+		"%d users":    "%d utilisateurs",
+	}
+
+	check := checkLocale("fr-FR", current, extracted, slog.Default())
+
+	assert.True(t, check.missing)
+	assert.Equal(t, 1, check.correct)
+	assert.Equal(t, map[string]any{
+		"%d items":    map[string]string{"one": "%d article", "other": "%d articles"},
+		"%d articles": map[string]string{"one": "", "other": "%d articles"},
+		"%d users":    map[string]string{"one": "", "other": ""},
+		"%d files":    map[string]string{"one": "", "other": ""},
+	}, check.locales)
+}
+
+func TestCheckLocale_pluralFormArgs(t *testing.T) {
+	extracted := map[string]extractedKey{"%d items": {Args: 1, Plural: true}}
+	current := map[string]any{
+		"%d items": map[string]any{"one": "un article", "other": "%d articles", "many": "%d articles"},
+	}
+
+	check := checkLocale("en-GB", current, extracted, slog.Default())
+
+	assert.False(t, check.missing)
+	assert.Equal(t, 0, check.correct)
+	assert.Equal(t, map[string]any{"%d items": map[string]string{"one": "", "other": "%d articles"}}, check.locales)
+}
+
+func TestCheckLocale_messageFormatArgs(t *testing.T) {
+	extracted := map[string]extractedKey{
+		"{name} has {count, plural, one {# item} other {# items}}": {Args: 1, MessageFormat: true},
+		"{count, number} visits":                                   {Args: 1, MessageFormat: true},
+		"Hello %s, you have %d messages":                           {Args: 2},
+		"Hello {name}":                                             {Args: 1, MessageFormat: true},
+	}
+	current := map[string]any{
+		"{name} has {count, plural, one {# item} other {# items}}": "{name} a {count, plural, one {# article} other {# articles}}",
+		"{count, number} visits":                                   "{count} visites",
+		"Hello %s, you have %d messages":                           "Bonjour %s, vous avez %s messages",
+		"Hello {name}":                                             "Bonjour {name",
+	}
+
+	check := checkLocale("fr-FR", current, extracted, slog.Default())
+
+	assert.Equal(t, 1, check.correct)
+	assert.Equal(t, map[string]any{
+		"{name} has {count, plural, one {# item} other {# items}}": "{name} a {count, plural, one {# article} other {# articles}}",
+		"{count, number} visits":                                   "",
+		"Hello %s, you have %d messages":                           "",
+		"Hello {name}":                                             "",
+	}, check.locales)
+}
+
+func TestCheckLocale_printfBraces(t *testing.T) {
+	extracted := map[string]extractedKey{
+		"Use {braces} for %s": {Args: 1},
+		"Hello {name}":        {Args: 0},
+		"Style: %s":           {Args: 1},
+		"Total: %d":           {Args: 1},
+	}
+	current := map[string]any{
+		"Use {braces} for %s": "Utilisez {accolades} pour %s",
+		"Hello {name}":        "Bonjour {nom}",
+		"Style: %s":           "Style : %s }",
+		"Total: %d":           "Total : %s {",
+	}
+
+	check := checkLocale("fr-FR", current, extracted, slog.Default())
+
+	assert.Equal(t, 3, check.correct)
+	assert.Equal(t, map[string]any{
+		"Use {braces} for %s": "Utilisez {accolades} pour %s",
+		"Hello {name}":        "Bonjour {nom}",
+		"Style: %s":           "Style : %s }",
+		"Total: %d":           "",
+	}, check.locales)
+}
+
+func TestExtractMessageFormatKeys(t *testing.T) {
+	content := `{ ki18n.Tr(ctx, "{name} has {count, plural, one {# item} other {# items}}", ki18n.Args{"name": user.Name, "count": len(items)}) }` +
+		`@ki18n.Tr(ctx, "Hello {name} :)", ki18n.Args{"name": fmt.Sprint(user.ID)})`
+
+	keys := extractKeys(content, nil)[ki18n.DefaultNamespace]
+
+	assert.Len(t, keys, 2)
+	assert.Equal(t, extractedKey{Args: 1, MessageFormat: true}, keys["{name} has {count, plural, one {# item} other {# items}}"])
+	assert.Equal(t, extractedKey{Args: 1, MessageFormat: true}, keys["Hello {name} :)"])
+}
+
+func TestExtractTrHTMLKeys(t *testing.T) {
+	content := `@ki18n.TrHTML(ctx, "Hello <b>%s</b>", user.Name)<input placeholder={ ki18n.Str(ctx, "Search") }/>`
+
+	keys := extractKeys(content, nil)[ki18n.DefaultNamespace]
+
+	assert.Len(t, keys, 2)
+	assert.Equal(t, 1, keys["Hello <b>%s</b>"].Args)
+	assert.Equal(t, 0, keys["Search"].Args)
+}
+
+func TestExtractScopedKeys(t *testing.T) {
+	content := `@ki18n.Context("verb").Tr(ctx, "Close")` +
+		`{ ki18n.Namespace("billing").TrN(ctx, "%d invoices", count) }` +
+		`<button title={ ki18n.Namespace("billing").Context("verb").Str(ctx, "Close") }></button>` +
+		`@ki18n.Tr(ctx, "Close")`
+
+	keys := extractKeys(content, nil)
+
+	assert.Equal(t, map[string]map[string]extractedKey{
+		ki18n.DefaultNamespace: {"Close": {}, "verb\x04Close": {}},
+		"billing":              {"%d invoices": {Args: 1, Plural: true}, "verb\x04Close": {}},
+	}, keys)
+}
+
+func TestExtractScopeVariables(t *testing.T) {
+	scopes := extractScopes(`billing := ki18n.Namespace("billing")
+var closeVerb = ki18n.Namespace("billing").Context("verb")
+module := ki18n.Namespace(name)`)
+	content := `@billing.Tr(ctx, "Pay")` +
+		`{ closeVerb.Str(ctx, "Close") }` +
+		`{ billing.Context("noun").Str(ctx, "Close") }`
+
+	keys := extractKeys(content, scopes)
+
+	assert.Equal(t, map[string]scope{"billing": {namespace: "billing"}, "closeVerb": {namespace: "billing", msgctxt: "verb"}}, scopes)
+	assert.Equal(t, map[string]map[string]extractedKey{
+		"billing": {"Pay": {}, "verb\x04Close": {}, "noun\x04Close": {}},
+	}, keys)
+}
+
+func TestExtractKeys_nonLiteralScope(t *testing.T) {
+	content := `{ ki18n.Namespace(module).Tr(ctx, "Pay") }` +
+		`@ki18n.Namespace(strings.ToLower(module)).Tr(ctx, "Pay")` +
+		`@unknown.Tr(ctx, "Pay")` +
+		`@ki18n.Tr(ctx, "Close")`
+
+	keys := extractKeys(content, nil)
+
+	assert.Equal(t, map[string]map[string]extractedKey{ki18n.DefaultNamespace: {"Close": {}}}, keys)
+}
+
+func TestLocaleNamespaces(t *testing.T) {
+	t.Chdir(t.TempDir())
+	require.NoError(t, os.MkdirAll(filepath.Join("locales", "fr-FR"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join("locales", "fr-FR", "modules.yml"), []byte("Pay: Payer\n"), 0o600))
+
+	namespaces := localeNamespaces([]string{"fr-FR"}, map[string]map[string]extractedKey{"billing": {"Pay": {}}}, slog.Default())
+
+	assert.Equal(t, []string{ki18n.DefaultNamespace, "billing"}, namespaces, "namespace files without extracted keys are not emptied")
+}
+
+func TestContexts(t *testing.T) {
+	locales := map[string]any{
+		"Close":      "Fermer",
+		"@adjective": map[string]any{"Close": "Proche", "%d days": map[string]any{"one": "%d jour", "other": "%d jours"}},
+		"@me":        "Moi",
+	}
+
+	flat := flattenContexts(locales)
+
+	assert.Equal(t, map[string]any{
+		"Close":                "Fermer",
+		"adjective\x04Close":   "Proche",
+		"adjective\x04%d days": map[string]any{"one": "%d jour", "other": "%d jours"},
+		"@me":                  "Moi",
+	}, flat)
+	assert.Equal(t, locales, nestContexts(flat))
+}
+
+func TestExtractMessageFormatKeys_argsVariable(t *testing.T) {
+	content := `@ki18n.Tr(ctx, "Hi {name}", args)@ki18n.Tr(ctx, "Use {braces} for %s", value)`
+
+	keys := extractKeys(content, nil)[ki18n.DefaultNamespace]
+
+	assert.True(t, keys["Hi {name}"].MessageFormat)
+	assert.False(t, keys["Use {braces} for %s"].MessageFormat)
 }
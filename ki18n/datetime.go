@@ -0,0 +1,323 @@
+package ki18n
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/text/message"
+)
+
+// A Style is the length of a formatted date or time, named like ICU MessageFormat styles
+type Style string
+
+const (
+	StyleShort  Style = "short"
+	StyleMedium Style = "medium"
+	StyleLong   Style = "long"
+	StyleFull   Style = "full"
+)
+
+// Now is the clock of FormatRelative, for translators without their own
+var Now = time.Now // injectable time provider
+
+var frenchMonths = [...]string{
+	"janvier", "février", "mars", "avril", "mai", "juin",
+	"juillet", "août", "septembre", "octobre", "novembre", "décembre",
+}
+
+var frenchShortMonths = [...]string{
+	"janv.", "févr.", "mars", "avr.", "mai", "juin",
+	"juil.", "août", "sept.", "oct.", "nov.", "déc.",
+}
+
+var frenchDays = [...]string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"}
+
+func englishDate(t time.Time, style Style) string {
+	switch style {
+	case StyleShort:
+		return t.Format("02/01/2006")
+	case StyleLong:
+		return t.Format("2 January 2006")
+	case StyleFull:
+		return t.Format("Monday 2 January 2006")
+	default:
+		return t.Format("2 Jan 2006")
+	}
+}
+
+func frenchDate(t time.Time, style Style) string {
+	switch style {
+	case StyleShort:
+		return t.Format("02/01/2006")
+	case StyleLong:
+		return fmt.Sprintf("%d %s %d", t.Day(), frenchMonths[t.Month()-1], t.Year())
+	case StyleFull:
+		return fmt.Sprintf("%s %d %s %d", frenchDays[t.Weekday()], t.Day(), frenchMonths[t.Month()-1], t.Year())
+	default:
+		return fmt.Sprintf("%d %s %d", t.Day(), frenchShortMonths[t.Month()-1], t.Year())
+	}
+}
+
+// formatTimeOfDay uses the 24-hour clock, with the zone abbreviation in long and its name in full
+func formatTimeOfDay(t time.Time, style Style) string {
+	switch style {
+	case StyleShort:
+		return t.Format("15:04")
+	case StyleLong:
+		return t.Format("15:04:05 MST")
+	case StyleFull:
+		return t.Format("15:04:05 ") + t.Location().String()
+	default:
+		return t.Format("15:04:05")
+	}
+}
+
+// frenchDateTime joins the date and time with "à" in long and full styles: "12 mai 2026 à 14:30:00 CEST"
+func frenchDateTime(t time.Time, style Style) string {
+	separator := " "
+	if style == StyleLong || style == StyleFull {
+		separator = " à "
+	}
+	return frenchDate(t, style) + separator + formatTimeOfDay(t, style)
+}
+
+// durationNames are the words of relative times and durations in a language
+type durationNames struct {
+	now    string
+	past   string
+	future string
+	// units are the singular and plural of seconds, minutes, hours, days, months and years
+	units [6][2]string
+}
+
+const (
+	unitSecond = iota
+	unitMinute
+	unitHour
+	unitDay
+	unitMonth
+	unitYear
+)
+
+var englishDurations = durationNames{
+	now:    "now",
+	past:   "%s ago",
+	future: "in %s",
+	units: [6][2]string{
+		{"second", "seconds"}, {"minute", "minutes"}, {"hour", "hours"},
+		{"day", "days"}, {"month", "months"}, {"year", "years"},
+	},
+}
+
+var frenchDurations = durationNames{
+	now:    "maintenant",
+	past:   "il y a %s",
+	future: "dans %s",
+	units: [6][2]string{
+		{"seconde", "secondes"}, {"minute", "minutes"}, {"heure", "heures"},
+		{"jour", "jours"}, {"mois", "mois"}, {"an", "ans"},
+	},
+}
+
+// quantity formats a count of a unit, choosing the singular with the plural rules of the language
+func (names durationNames) quantity(lang string, p *message.Printer, n int, unit int) string {
+	form := names.units[unit][1]
+	if PluralCategory(lang, n) == "one" {
+		form = names.units[unit][0]
+	}
+	return p.Sprint(n) + " " + form
+}
+
+// formatRelative rounds the distance to the largest relevant unit, such as "3 days ago" or "in 2 hours"
+func formatRelative(lang string, names durationNames) func(time.Duration) string {
+	p := message.NewPrinter(pluralTag(lang))
+	return func(d time.Duration) string {
+		distance := d.Abs()
+		var quantity string
+		switch {
+		case distance < 45*time.Second:
+			return names.now
+		case distance < 45*time.Minute:
+			quantity = names.quantity(lang, p, max(roundUnits(distance, time.Minute), 1), unitMinute)
+		case distance < 22*time.Hour:
+			quantity = names.quantity(lang, p, roundUnits(distance, time.Hour), unitHour)
+		case distance < 26*24*time.Hour:
+			quantity = names.quantity(lang, p, roundUnits(distance, 24*time.Hour), unitDay)
+		case distance < 320*24*time.Hour:
+			quantity = names.quantity(lang, p, max(roundUnits(distance, 30*24*time.Hour), 1), unitMonth)
+		default:
+			quantity = names.quantity(lang, p, max(roundUnits(distance, 365*24*time.Hour), 1), unitYear)
+		}
+		if d < 0 {
+			return fmt.Sprintf(names.past, quantity)
+		}
+		return fmt.Sprintf(names.future, quantity)
+	}
+}
+
+func roundUnits(d time.Duration, unit time.Duration) int {
+	return int(math.Round(float64(d) / float64(unit)))
+}
+
+// formatDuration writes the two largest units of a duration, such as "2 hours 5 minutes"
+func formatDuration(lang string, names durationNames) func(time.Duration) string {
+	p := message.NewPrinter(pluralTag(lang))
+	return func(d time.Duration) string {
+		d = d.Abs().Round(time.Second)
+		parts := []string{}
+		for _, unit := range []struct {
+			index    int
+			duration time.Duration
+		}{{unitDay, 24 * time.Hour}, {unitHour, time.Hour}, {unitMinute, time.Minute}, {unitSecond, time.Second}} {
+			if n := int(d / unit.duration); n > 0 && len(parts) < 2 {
+				parts = append(parts, names.quantity(lang, p, n, unit.index))
+				d -= time.Duration(n) * unit.duration
+			} else if len(parts) > 0 {
+				// Only adjacent units are shown: 1 day 2 hours, not 1 day 30 seconds
+				break
+			}
+		}
+		if len(parts) == 0 {
+			return names.quantity(lang, p, 0, unitSecond)
+		}
+		return strings.Join(parts, " ")
+	}
+}
+
+// withDefaultTimeFormats completes the time formats of a locale with the formats of fallback, keeping FormatTime as the long date
+func withDefaultTimeFormats(loc Locale, fallback Locale) Locale {
+	if loc.FormatDate == nil {
+		formatTime := loc.FormatTime
+		loc.FormatDate = func(t time.Time, style Style) string {
+			if style == StyleLong && formatTime != nil {
+				return formatTime(t)
+			}
+			return fallback.FormatDate(t, style)
+		}
+	}
+	if loc.FormatTime == nil {
+		formatDate := loc.FormatDate
+		loc.FormatTime = func(t time.Time) string { return formatDate(t, StyleLong) }
+	}
+	if loc.FormatTimeOfDay == nil {
+		loc.FormatTimeOfDay = fallback.FormatTimeOfDay
+	}
+	if loc.FormatDateTime == nil {
+		formatDate, formatTimeOfDay := loc.FormatDate, loc.FormatTimeOfDay
+		loc.FormatDateTime = func(t time.Time, style Style) string {
+			return formatDate(t, style) + ", " + formatTimeOfDay(t, style)
+		}
+	}
+	if loc.FormatRelative == nil {
+		loc.FormatRelative = fallback.FormatRelative
+	}
+	if loc.FormatDuration == nil {
+		loc.FormatDuration = fallback.FormatDuration
+	}
+	return loc
+}
+
+type zoneContextKey struct{}
+
+// WithZone sets the time zone used to format times
+func WithZone(ctx context.Context, zone *time.Location) context.Context {
+	return context.WithValue(ctx, zoneContextKey{}, zone)
+}
+
+// Zone returns the time zone of the context, set by ZoneMiddleware or WithZone
+func Zone(ctx context.Context) (*time.Location, bool) {
+	zone, ok := ctx.Value(zoneContextKey{}).(*time.Location)
+	return zone, ok
+}
+
+// ZoneMiddleware sets the time zone of the first strategy detecting a valid IANA zone name, such as a cookie set from
+// Intl.DateTimeFormat().resolvedOptions().timeZone. Without a zone, times are formatted in their own location.
+func ZoneMiddleware(strategies ...Strategy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, s := range strategies {
+				name := s.Detect(r)
+				if name == "" {
+					continue
+				}
+				if zone, err := loadZone(name); err == nil {
+					r = r.WithContext(WithZone(r.Context(), zone))
+					break
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Loaded zones by name. Only valid names are cached, so that clients can't grow the cache with made up names.
+var zones sync.Map
+
+// loadZone is time.LoadLocation without reading zoneinfo on every request
+func loadZone(name string) (*time.Location, error) {
+	if zone, ok := zones.Load(name); ok {
+		return zone.(*time.Location), nil //nolint:forcetypeassert
+	}
+	zone, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	zones.Store(name, zone)
+	return zone, nil
+}
+
+func inZone(ctx context.Context, t time.Time) time.Time {
+	if zone, ok := Zone(ctx); ok {
+		return t.In(zone)
+	}
+	return t
+}
+
+// argsInZone converts the times of named args to the zone of the context
+func argsInZone(ctx context.Context, args []any) []any {
+	if len(args) != 1 {
+		return args
+	}
+	named, ok := args[0].(Args)
+	if !ok {
+		return args
+	}
+	converted := make(Args, len(named))
+	for name, value := range named {
+		if t, ok := value.(time.Time); ok {
+			value = inZone(ctx, t)
+		}
+		converted[name] = value
+	}
+	return []any{converted}
+}
+
+// FormatDate formats the date of t in the zone of the context, such as "12/05/2026", "12 May 2026" or "Tuesday 12 May 2026"
+func FormatDate(ctx context.Context, t time.Time, style Style) string {
+	return localeFrom(ctx).FormatDate(inZone(ctx, t), style)
+}
+
+// FormatTimeOfDay formats the time of t in the zone of the context, such as "14:30" or "14:30:00 CEST"
+func FormatTimeOfDay(ctx context.Context, t time.Time, style Style) string {
+	return localeFrom(ctx).FormatTimeOfDay(inZone(ctx, t), style)
+}
+
+// FormatDateTime formats the date and time of t in the zone of the context, such as "12 May 2026, 14:30:00"
+func FormatDateTime(ctx context.Context, t time.Time, style Style) string {
+	return localeFrom(ctx).FormatDateTime(inZone(ctx, t), style)
+}
+
+// FormatRelative formats t relatively to the Now of the translator, such as "3 days ago" or "dans 2 heures"
+func FormatRelative(ctx context.Context, t time.Time) string {
+	return localeFrom(ctx).FormatRelative(t.Sub(translatorFrom(ctx).now()))
+}
+
+// FormatDuration formats d with its two largest units, such as "2 hours 5 minutes"
+func FormatDuration(ctx context.Context, d time.Duration) string {
+	return localeFrom(ctx).FormatDuration(d)
+}
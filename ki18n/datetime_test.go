@@ -0,0 +1,103 @@
+package ki18n
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatDate_styles(t *testing.T) {
+	initLocales(t, map[string]string{})
+	en := context.WithValue(context.Background(), contextKey{}, "en-GB")
+	fr := context.WithValue(context.Background(), contextKey{}, "fr-FR")
+	d := time.Date(2026, time.February, 3, 14, 30, 5, 0, time.UTC)
+
+	assert.Equal(t, "03/02/2026", FormatDate(en, d, StyleShort))
+	assert.Equal(t, "3 Feb 2026", FormatDate(en, d, StyleMedium))
+	assert.Equal(t, "3 February 2026", FormatDate(en, d, StyleLong))
+	assert.Equal(t, "Tuesday 3 February 2026", FormatDate(en, d, StyleFull))
+	assert.Equal(t, "3 févr. 2026", FormatDate(fr, d, StyleMedium))
+	assert.Equal(t, "mardi 3 février 2026", FormatDate(fr, d, StyleFull))
+	assert.Equal(t, "14:30", FormatTimeOfDay(en, d, StyleShort))
+	assert.Equal(t, "14:30:05 UTC", FormatTimeOfDay(fr, d, StyleLong))
+	assert.Equal(t, "3 Feb 2026, 14:30:05", FormatDateTime(en, d, StyleMedium))
+	assert.Equal(t, "3 février 2026 à 14:30:05 UTC", FormatDateTime(fr, d, StyleLong))
+}
+
+func TestFormatDate_extraLocale(t *testing.T) {
+	assert.NoError(t, Init(fstest.MapFS{}, Locale{
+		Lang:       "es-ES",
+		FormatTime: func(t time.Time) string { return "el " + t.Format("2/1/2006") },
+	}))
+	ctx := context.WithValue(context.Background(), contextKey{}, "es-ES")
+	d := time.Date(2026, time.May, 12, 9, 5, 0, 0, time.UTC)
+
+	assert.Equal(t, "el 12/5/2026", FormatDate(ctx, d, StyleLong), "FormatTime is the long date")
+	assert.Equal(t, "12/05/2026, 09:05", FormatDateTime(ctx, d, StyleShort))
+}
+
+func TestFormatRelative(t *testing.T) {
+	initLocales(t, map[string]string{})
+	en := context.WithValue(context.Background(), contextKey{}, "en-GB")
+	fr := context.WithValue(context.Background(), contextKey{}, "fr-FR")
+	now := time.Date(2026, time.May, 12, 12, 0, 0, 0, time.UTC)
+	Now = func() time.Time { return now }
+	t.Cleanup(func() { Now = time.Now })
+
+	assert.Equal(t, "now", FormatRelative(en, now.Add(-10*time.Second)))
+	assert.Equal(t, "1 minute ago", FormatRelative(en, now.Add(-50*time.Second)))
+	assert.Equal(t, "3 days ago", FormatRelative(en, now.Add(-70*time.Hour)))
+	assert.Equal(t, "in 2 hours", FormatRelative(en, now.Add(2*time.Hour)))
+	assert.Equal(t, "dans 2 heures", FormatRelative(fr, now.Add(2*time.Hour)))
+	assert.Equal(t, "il y a 1 an", FormatRelative(fr, now.AddDate(-1, 0, 0)))
+	assert.Equal(t, "il y a 3 mois", FormatRelative(fr, now.AddDate(0, -3, 0)))
+}
+
+func TestFormatDuration(t *testing.T) {
+	initLocales(t, map[string]string{})
+	en := context.WithValue(context.Background(), contextKey{}, "en-GB")
+	fr := context.WithValue(context.Background(), contextKey{}, "fr-FR")
+
+	assert.Equal(t, "2 hours 5 minutes", FormatDuration(en, 2*time.Hour+5*time.Minute+3*time.Second))
+	assert.Equal(t, "1 day", FormatDuration(en, 24*time.Hour+30*time.Second))
+	assert.Equal(t, "0 seconds", FormatDuration(en, 0))
+	assert.Equal(t, "1 heure 30 minutes", FormatDuration(fr, 90*time.Minute))
+}
+
+func TestZoneMiddleware(t *testing.T) {
+	initLocales(t, map[string]string{})
+	d := time.Date(2026, time.May, 12, 23, 30, 0, 0, time.UTC)
+	var date, message string
+	handler := ZoneMiddleware(strategyFunc(func(_ *http.Request) string { return "Not/AZone" }), CookieStrategy("tz"))(
+		http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			date = FormatDateTime(r.Context(), d, StyleShort)
+			message = render(t, Tr(r.Context(), "Due {due, date, short} at {due, time, short}", Args{"due": d}))
+		}),
+	)
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: "tz", Value: "Europe/Paris"})
+
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Equal(t, "13/05/2026, 01:30", date)
+	assert.Equal(t, "Due 13/05/2026 at 01:30", message)
+	assert.Equal(t, "12/05/2026, 23:30", FormatDateTime(context.Background(), d, StyleShort), "times keep their location without a zone")
+}
+
+func TestLoadZone(t *testing.T) {
+	first, err := loadZone("Europe/Paris")
+	require.NoError(t, err)
+	second, err := loadZone("Europe/Paris")
+	require.NoError(t, err)
+	assert.Same(t, first, second, "loaded zones are cached")
+
+	_, err = loadZone("Not/AZone")
+	assert.Error(t, err)
+	_, cached := zones.Load("Not/AZone")
+	assert.False(t, cached, "invalid names are not cached")
+}
@@ -0,0 +1,107 @@
+package ki18n
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"reflect"
+
+	"github.com/a-h/templ"
+)
+
+// An output escapes the translation text and the args of a message, nil functions keep them as is
+type output struct {
+	text func(string) string
+	args func(string) string
+}
+
+var (
+	// plainOutput is for strings, which templ escapes where they are used
+	plainOutput = output{}
+	// htmlOutput is for untrusted translations
+	htmlOutput = output{text: html.EscapeString, args: html.EscapeString}
+	// trustedOutput is for translations written in HTML, with untrusted args
+	trustedOutput = output{args: html.EscapeString}
+)
+
+func (out output) escapeText(text string) string {
+	if out.text == nil {
+		return text
+	}
+	return out.text(text)
+}
+
+func (out output) escapeArg(arg string) string {
+	if out.args == nil {
+		return arg
+	}
+	return out.args(arg)
+}
+
+// escapePrintfArgs escapes the args once formatted, so that verbs still apply, such as %d on a time.Duration.
+// Numbers and booleans without String or Error method are kept as is, for width and precision args.
+func (out output) escapePrintfArgs(args []any) []any {
+	if out.args == nil {
+		return args
+	}
+	escaped := make([]any, len(args))
+	for i, arg := range args {
+		if isPlainScalar(arg) {
+			escaped[i] = arg
+			continue
+		}
+		escaped[i] = escapedArg{value: arg, escape: out.args}
+	}
+	return escaped
+}
+
+func isPlainScalar(arg any) bool {
+	switch arg.(type) {
+	case error, fmt.Stringer:
+		return false
+	}
+	switch reflect.ValueOf(arg).Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return true
+	}
+	return false
+}
+
+// An escapedArg formats its value with the verb and flags of the translation, then escapes the result
+type escapedArg struct {
+	value  any
+	escape func(string) string
+}
+
+func (arg escapedArg) Format(f fmt.State, verb rune) {
+	_, _ = f.Write([]byte(arg.escape(fmt.Sprintf(fmt.FormatString(f, verb), arg.value))))
+}
+
+// Tr translates a key, escaping the translation and the args for HTML:
+//
+//	@ki18n.Tr(ctx, "Hello %s", user.Name)
+func Tr(ctx context.Context, key string, args ...any) templ.Component {
+	return Scope{}.Tr(ctx, key, args...)
+}
+
+// TrHTML translates a key written in trusted HTML, escaping only the args:
+//
+//	@ki18n.TrHTML(ctx, "Hello <b>%s</b>", user.Name)
+func TrHTML(ctx context.Context, key string, args ...any) templ.Component {
+	return Scope{}.TrHTML(ctx, key, args...)
+}
+
+// TrN translates a key with plural forms, choosing the CLDR category of count in the language, escaped like Tr.
+// The count is the first format arg: TrN(ctx, "%d items in %s", count, folder)
+func TrN(ctx context.Context, key string, count int, args ...any) templ.Component {
+	return Scope{}.TrN(ctx, key, count, args...)
+}
+
+// Str translates a key to a plain string, for attributes and code outside of templates: templ escapes it where it is used.
+//
+//	<input placeholder={ ki18n.Str(ctx, "Username") }/>
+func Str(ctx context.Context, key string, args ...any) string {
+	return Scope{}.Str(ctx, key, args...)
+}
@@ -0,0 +1,78 @@
+package ki18n
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTr_escapesArgs(t *testing.T) {
+	initFrenchLocale(map[string]string{
+		"Hello %s, you have %d messages": "Bonjour %s, vous avez %d messages",
+		"Hello <b>%s</b>":                "Bonjour <b>%s</b>",
+		"Tom & Jerry":                    "Tom & Jerry",
+		"{name} replied":                 "{name} a répondu",
+	})
+	ctx := context.WithValue(context.Background(), contextKey{}, "fr-FR")
+	name := "<script>alert(1)</script>"
+
+	assert.Equal(t, "Bonjour &lt;script&gt;alert(1)&lt;/script&gt;, vous avez 1234 messages", render(t, Tr(ctx, "Hello %s, you have %d messages", name, 1234)))
+	assert.Equal(t, "Bonjour <b>&lt;script&gt;alert(1)&lt;/script&gt;</b>", render(t, TrHTML(ctx, "Hello <b>%s</b>", name)))
+	assert.Equal(t, "Bonjour &lt;b&gt;Ann&lt;/b&gt;", render(t, Tr(ctx, "Hello <b>%s</b>", "Ann")), "translations are trusted with TrHTML only")
+	assert.Equal(t, "Tom &amp; Jerry", render(t, Tr(ctx, "Tom & Jerry")))
+	assert.Equal(t, "&lt;script&gt;alert(1)&lt;/script&gt; a répondu", render(t, Tr(ctx, "{name} replied", Args{"name": name})))
+	assert.Equal(t, "Bonjour &lt;i&gt;, vous avez 2 messages", render(t, Tr(ctx, "Hello %s, you have %d messages", errors.New("<i>"), 2)))
+}
+
+type status int
+
+func (s status) String() string { return "<active>" }
+
+func TestTr_escapesStringerArgs(t *testing.T) {
+	initFrenchLocale(map[string]string{
+		"Retry in %d s":  "Réessayer dans %d s",
+		"Retry in %v":    "Réessayer dans %v",
+		"Status %d (%s)": "Statut %d (%s)",
+		"Hello %-6s|":    "Bonjour %-6s|",
+	})
+	ctx := context.WithValue(context.Background(), contextKey{}, "fr-FR")
+
+	assert.Equal(t, "Réessayer dans 5 s", render(t, Tr(ctx, "Retry in %d s", time.Duration(5))))
+	assert.Equal(t, "Réessayer dans 5s", render(t, Tr(ctx, "Retry in %v", 5*time.Second)))
+	assert.Equal(t, "Statut 1 (&lt;active&gt;)", render(t, Tr(ctx, "Status %d (%s)", status(1), status(1))))
+	assert.Equal(t, "Bonjour &lt;b&gt;   |", render(t, Tr(ctx, "Hello %-6s|", "<b>")), "flags apply before escaping")
+}
+
+type userName string
+
+func TestTr_escapesAnyArg(t *testing.T) {
+	initFrenchLocale(map[string]string{
+		"Hi %s":            "Salut %s",
+		"Hi %v":            "Salut %v",
+		"%*d items":        "%*d articles",
+		"Enabled: %t %d%%": "Activé : %t %d%%",
+	})
+	ctx := context.WithValue(context.Background(), contextKey{}, "fr-FR")
+
+	assert.Equal(t, "Salut &lt;script&gt;", render(t, Tr(ctx, "Hi %s", userName("<script>"))), "named string types are escaped")
+	assert.Equal(t, "Salut [&lt;b&gt;]", render(t, Tr(ctx, "Hi %s", []string{"<b>"})))
+	assert.Equal(t, "Salut {&lt;b&gt;}", render(t, Tr(ctx, "Hi %v", struct{ N string }{"<b>"})))
+	assert.Equal(t, "Salut map[&lt;b&gt;:1]", render(t, Tr(ctx, "Hi %v", map[string]int{"<b>": 1})))
+	assert.Equal(t, "   42 articles", render(t, Tr(ctx, "%*d items", 5, 42)), "numbers are kept for width args")
+	assert.Equal(t, "Activé : true 50%", render(t, Tr(ctx, "Enabled: %t %d%%", true, 50)))
+}
+
+func TestStr(t *testing.T) {
+	initFrenchLocale(map[string]string{
+		"Hello %s":    "Bonjour %s",
+		"Tom & Jerry": "Tom & Jerry",
+	})
+	ctx := context.WithValue(context.Background(), contextKey{}, "fr-FR")
+
+	assert.Equal(t, "Bonjour <b>", Str(ctx, "Hello %s", "<b>"))
+	assert.Equal(t, "Tom & Jerry", Str(ctx, "Tom & Jerry"))
+	assert.Equal(t, "Missing", Str(ctx, "Missing"))
+}
@@ -0,0 +1,113 @@
+package ki18n
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"golang.org/x/text/currency"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+)
+
+// Languages that write the currency symbol after the amount, separated by a no-break space (CLDR "#,##0.00 ¤")
+var symbolAfterAmount = []string{
+	"bg", "ca", "cs", "da", "de", "el", "es", "et", "fi", "fr", "hr", "hu",
+	"it", "lt", "lv", "nb", "pl", "pt", "ro", "ru", "sk", "sl", "sv", "uk",
+}
+
+var (
+	byteUnits  = []string{"B", "kB", "MB", "GB", "TB", "PB"}
+	octetUnits = []string{"o", "ko", "Mo", "Go", "To", "Po"}
+)
+
+func languageBase(lang string) string {
+	base, _ := pluralTag(lang).Base()
+	return base.String()
+}
+
+func formatNumber(p *message.Printer) func(float64) string {
+	return func(n float64) string {
+		return p.Sprint(number.Decimal(n))
+	}
+}
+
+// formatCurrency rounds the amount to the digits of the currency, and writes its symbol in the language
+func formatCurrency(p *message.Printer, lang string) func(float64, string) string {
+	after := slices.Contains(symbolAfterAmount, languageBase(lang))
+	return func(amount float64, code string) string {
+		unit, err := currency.ParseISO(code)
+		if err != nil {
+			return p.Sprint(number.Decimal(amount, number.Scale(2))) + "\u00a0" + code
+		}
+		scale, _ := currency.Standard.Rounding(unit)
+		formatted := p.Sprint(number.Decimal(amount, number.Scale(scale)))
+		symbol := p.Sprint(currency.Symbol(unit))
+		if after {
+			return formatted + "\u00a0" + symbol
+		}
+		if sign, ok := strings.CutPrefix(formatted, "-"); ok {
+			return "-" + symbol + sign
+		}
+		return symbol + formatted
+	}
+}
+
+func formatPercent(p *message.Printer) func(float64) string {
+	return func(ratio float64) string {
+		return p.Sprint(number.Percent(ratio))
+	}
+}
+
+// formatBytes uses decimal units (1 kB = 1000 B) with one fraction digit
+func formatBytes(p *message.Printer, units []string) func(int64) string {
+	return func(n int64) string {
+		size, unit := float64(n), 0
+		for (size >= 1000 || size <= -1000) && unit < len(units)-1 {
+			size /= 1000
+			unit++
+		}
+		return p.Sprint(number.Decimal(size, number.MaxFractionDigits(1))) + "\u00a0" + units[unit]
+	}
+}
+
+// withDefaultFormats completes the number formats of a locale with the conventions of its language
+func withDefaultFormats(loc Locale, p *message.Printer) Locale {
+	if loc.FormatNumber == nil {
+		loc.FormatNumber = formatNumber(p)
+	}
+	if loc.FormatCurrency == nil {
+		loc.FormatCurrency = formatCurrency(p, loc.Lang)
+	}
+	if loc.FormatPercent == nil {
+		loc.FormatPercent = formatPercent(p)
+	}
+	if loc.FormatBytes == nil {
+		units := byteUnits
+		if languageBase(loc.Lang) == "fr" {
+			units = octetUnits
+		}
+		loc.FormatBytes = formatBytes(p, units)
+	}
+	return loc
+}
+
+// FormatNumber formats n with the decimal and grouping separators of the language: 1,234.5 or 1 234,5
+func FormatNumber(ctx context.Context, n float64) string {
+	return localeFrom(ctx).FormatNumber(n)
+}
+
+// FormatCurrency formats an amount of an ISO 4217 currency, such as "€1,234.56" or "1 234,56 €" for EUR
+func FormatCurrency(ctx context.Context, amount float64, currency string) string {
+	return localeFrom(ctx).FormatCurrency(amount, currency)
+}
+
+// FormatPercent formats a ratio as a percentage: 0.25 is 25%
+func FormatPercent(ctx context.Context, ratio float64) string {
+	return localeFrom(ctx).FormatPercent(ratio)
+}
+
+// FormatBytes formats a size with decimal units, such as 1.5 MB
+func FormatBytes(ctx context.Context, n int64) string {
+	return localeFrom(ctx).FormatBytes(n)
+}
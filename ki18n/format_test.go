@@ -0,0 +1,47 @@
+package ki18n
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatCurrency(t *testing.T) {
+	initLocales(t, map[string]string{})
+	en := context.WithValue(context.Background(), contextKey{}, "en-GB")
+	fr := context.WithValue(context.Background(), contextKey{}, "fr-FR")
+
+	assert.Equal(t, "€1,234.56", FormatCurrency(en, 1234.56, "EUR"))
+	assert.Equal(t, "-£3.50", FormatCurrency(en, -3.5, "GBP"))
+	assert.Equal(t, "1\u00a0234,56\u00a0€", FormatCurrency(fr, 1234.56, "EUR"))
+	assert.Equal(t, "1\u00a0235\u00a0JPY", FormatCurrency(fr, 1234.56, "JPY"), "yens have no decimals")
+	assert.Equal(t, "12.00\u00a0XYZ", FormatCurrency(en, 12, "XYZ"), "unknown currencies keep their code")
+}
+
+func TestFormatNumber(t *testing.T) {
+	initLocales(t, map[string]string{})
+	en := context.WithValue(context.Background(), contextKey{}, "en-GB")
+	fr := context.WithValue(context.Background(), contextKey{}, "fr-FR")
+
+	assert.Equal(t, "1,234.5", FormatNumber(en, 1234.5))
+	assert.Equal(t, "1\u00a0234,5", FormatNumber(fr, 1234.5))
+	assert.Equal(t, "25%", FormatPercent(en, 0.25))
+	assert.Equal(t, "25\u00a0%", FormatPercent(fr, 0.25))
+	assert.Equal(t, "512\u00a0B", FormatBytes(en, 512))
+	assert.Equal(t, "1.5\u00a0MB", FormatBytes(en, 1_500_000))
+	assert.Equal(t, "1,5\u00a0Mo", FormatBytes(fr, 1_500_000))
+}
+
+func TestFormatNumber_localeOverride(t *testing.T) {
+	assert.NoError(t, Init(fstest.MapFS{}, Locale{
+		Lang:           "de-CH",
+		FormatCurrency: func(amount float64, currency string) string { return fmt.Sprintf("%s %.2f", currency, amount) },
+	}))
+	ctx := context.WithValue(context.Background(), contextKey{}, "de-CH")
+
+	assert.Equal(t, "CHF 1234.50", FormatCurrency(ctx, 1234.5, "CHF"))
+	assert.Equal(t, "1’234.5", FormatNumber(ctx, 1234.5), "other formats use the conventions of the language")
+}
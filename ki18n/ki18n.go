@@ -2,18 +2,19 @@ package ki18n
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"io/fs"
 	"net/http"
 	"time"
-
-	"github.com/a-h/templ"
-	"github.com/kataras/i18n"
 )
 
 type contextKey struct{}
 
-const defaultLang = "en-GB"
+var ErrUnregisteredLang = errors.New("language not registered")
+
+// DefaultLang is the default language of the translator created by Init: the language used when no strategy
+// negotiates a registered language, and the last fallback of translations. It must be registered, and set before Init.
+var DefaultLang = "en-GB"
 
 type Strategy interface {
 	Detect(r *http.Request) string
@@ -36,106 +37,78 @@ func CookieStrategy(name string) Strategy {
 type acceptLanguageStrategy struct{}
 
 func (acceptLanguageStrategy) Detect(r *http.Request) string {
-	lang := i18n.Default.GetLocale(r).Language()
-	if lang == defaultLang {
-		if r.Header.Get("Accept-Language") == "" {
-			return ""
-		}
-	}
-	return lang
+	return translatorFrom(r.Context()).Negotiate(r.Header.Get("Accept-Language"))
 }
 
 var AcceptLanguageStrategy Strategy = acceptLanguageStrategy{}
 
+// LangMiddleware sets the language of the first strategy detecting a registered language, negotiated with Negotiate,
+// for the translator of the context or the default translator
 func LangMiddleware(strategies ...Strategy) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			lang := ""
-			for _, s := range strategies {
-				if l := s.Detect(r); l != "" {
-					lang = l
-					break
-				}
-			}
-			if lang == "" {
-				lang = defaultLang
-			}
-			ctx := context.WithValue(r.Context(), contextKey{}, lang)
-			next.ServeHTTP(w, r.WithContext(ctx))
+			translatorFrom(r.Context()).serveLang(w, r, next, strategies)
 		})
 	}
 }
 
-var frenchMonths = [...]string{
-	"janvier", "février", "mars", "avril", "mai", "juin",
-	"juillet", "août", "septembre", "octobre", "novembre", "décembre",
-}
-
 // Locale configures a language for use with ki18n. Lang is a BCP-47 tag (e.g. "es-ES").
-// FormatTime is optional; if nil, en-GB formatting is used.
+// FormatTime is the long date, and the other time formats are optional; if nil, en-GB formatting is used.
+// The number formats are optional; if nil, the separators and currency symbols of the language are used.
 type Locale struct {
-	Lang       string
-	FormatTime func(time.Time) string
+	Lang string
+	// Fallbacks are the registered languages of missing translations, before DefaultLang (e.g. fr-FR for fr-CA)
+	Fallbacks       []string
+	FormatTime      func(time.Time) string
+	FormatDate      func(time.Time, Style) string
+	FormatTimeOfDay func(time.Time, Style) string
+	FormatDateTime  func(time.Time, Style) string
+	// FormatRelative formats the distance from now to a time, negative in the past
+	FormatRelative func(time.Duration) string
+	FormatDuration func(time.Duration) string
+	FormatNumber   func(float64) string
+	FormatCurrency func(amount float64, currency string) string
+	FormatPercent  func(float64) string
+	FormatBytes    func(int64) string
 }
 
 var defaultLocales = []Locale{
 	{
-		Lang:       "en-GB",
-		FormatTime: func(t time.Time) string { return t.Format("2 January 2006") },
+		Lang:            "en-GB",
+		FormatTime:      func(t time.Time) string { return englishDate(t, StyleLong) },
+		FormatDate:      englishDate,
+		FormatTimeOfDay: formatTimeOfDay,
+		FormatRelative:  formatRelative("en-GB", englishDurations),
+		FormatDuration:  formatDuration("en-GB", englishDurations),
 	},
 	{
-		Lang: "fr-FR",
-		FormatTime: func(t time.Time) string {
-			return fmt.Sprintf("%d %s %d", t.Day(), frenchMonths[t.Month()-1], t.Year())
-		},
+		Lang:            "fr-FR",
+		FormatTime:      func(t time.Time) string { return frenchDate(t, StyleLong) },
+		FormatDate:      frenchDate,
+		FormatTimeOfDay: formatTimeOfDay,
+		FormatDateTime:  frenchDateTime,
+		FormatRelative:  formatRelative("fr-FR", frenchDurations),
+		FormatDuration:  formatDuration("fr-FR", frenchDurations),
 	},
 }
 
-var timeFormatters map[string]func(time.Time) string
-
 func FormatTime(ctx context.Context, t time.Time) string {
 	lang, _ := ctx.Value(contextKey{}).(string)
-	f, ok := timeFormatters[lang]
-	if !ok {
-		f = timeFormatters[defaultLang]
-	}
-	return f(t)
+	return translatorFrom(ctx).locale(lang).FormatTime(inZone(ctx, t))
 }
 
-func Tr(ctx context.Context, format string, args ...any) templ.Component {
-	lang, _ := ctx.Value(contextKey{}).(string)
-	if lang == "" {
-		lang = defaultLang
-	}
-	msg := i18n.Tr(lang, format, args...)
-	if msg == "" {
-		msg = format
-	}
-	return templ.Raw(msg)
+// Negotiate returns the language of the default translator best matching a BCP-47 tag or an Accept-Language header,
+// see Translator.Negotiate
+func Negotiate(value string) string {
+	return defaultTranslator.Load().Negotiate(value)
 }
 
+// Init replaces the default translator of the package functions, with DefaultLang as default language
 func Init(localesFS fs.FS, extra ...Locale) error {
-	all := append(defaultLocales, extra...)
-
-	langs := make([]string, len(all))
-	timeFormatters = make(map[string]func(time.Time) string, len(all))
-	for i, loc := range all {
-		langs[i] = loc.Lang
-		if loc.FormatTime != nil {
-			timeFormatters[loc.Lang] = loc.FormatTime
-		} else {
-			timeFormatters[loc.Lang] = timeFormatters[defaultLang]
-		}
-	}
-
-	loader, err := i18n.FS(localesFS, "*/*.yml")
-	if err != nil {
-		return err
-	}
-	inst, err := i18n.New(loader, langs...)
+	t, err := NewTranslator(localesFS, DefaultLang, extra...)
 	if err != nil {
 		return err
 	}
-	i18n.Default = inst
+	defaultTranslator.Store(t)
 	return nil
 }
@@ -42,7 +42,7 @@ func TestSpanClass(t *testing.T) {
 	})
 	ctx := context.WithValue(context.Background(), contextKey{}, "fr-FR")
 
-	result := render(t, Tr(ctx, "Hello <span class=\"text-bold\">%s</span>", "John"))
+	result := render(t, TrHTML(ctx, "Hello <span class=\"text-bold\">%s</span>", "John"))
 	assert.Equal(t, "Bonjour <span class=\"text-bold\">John</span>", result)
 }
 
@@ -209,3 +209,70 @@ func initFrenchLocale(translations map[string]string) {
 type strategyFunc func(*http.Request) string
 
 func (f strategyFunc) Detect(r *http.Request) string { return f(r) }
+
+// initLocales creates a filesystem with raw YAML files by language
+func initLocales(t *testing.T, files map[string]string) {
+	t.Helper()
+	fs := fstest.MapFS{}
+	for lang, content := range files {
+		fs[lang+"/index.yml"] = &fstest.MapFile{Data: []byte(content)}
+	}
+	assert.NoError(t, Init(fs))
+}
+
+func TestTr_printfArgs(t *testing.T) {
+	initLocales(t, map[string]string{"fr-FR": "\"Year %d\": \"Année %d\"\n"})
+	en := context.WithValue(context.Background(), contextKey{}, "en-GB")
+	fr := context.WithValue(context.Background(), contextKey{}, "fr-FR")
+
+	assert.Equal(t, "Year 2026", Str(en, "Year %d", 2026))
+	assert.Equal(t, "Année 2026", Str(fr, "Year %d", 2026))
+}
+
+func TestTrN(t *testing.T) {
+	initLocales(t, map[string]string{
+		"en-GB": "\"%d items\":\n  one: \"%d item\"\n  other: \"%d items\"\n\"%d items in %s\":\n  one: \"%d item in %s\"\n  other: \"%d items in %s\"\n",
+		"fr-FR": "\"%d items\":\n  one: \"%d article\"\n  other: \"%d articles\"\n",
+	})
+	en := context.WithValue(context.Background(), contextKey{}, "en-GB")
+	fr := context.WithValue(context.Background(), contextKey{}, "fr-FR")
+
+	assert.Equal(t, "1 item", render(t, TrN(en, "%d items", 1)))
+	assert.Equal(t, "0 items", render(t, TrN(en, "%d items", 0)))
+	assert.Equal(t, "2 items in Inbox", render(t, TrN(en, "%d items in %s", 2, "Inbox")))
+	assert.Equal(t, "0 article", render(t, TrN(fr, "%d items", 0)))
+	assert.Equal(t, "1 article", render(t, TrN(fr, "%d items", 1)))
+	assert.Equal(t, "1234 articles", render(t, TrN(fr, "%d items", 1234)), "printf verbs are formatted like fmt")
+	assert.Equal(t, "1 item in Inbox", render(t, TrN(fr, "%d items in %s", 1, "Inbox")), "falls back to the default language")
+	assert.Equal(t, "3 missing", render(t, TrN(fr, "%d missing", 3)))
+}
+
+func TestInit_invalidPluralForm(t *testing.T) {
+	fs := fstest.MapFS{
+		"fr-FR/index.yml": &fstest.MapFile{Data: []byte("\"%d items\":\n  one: [\"%d article\"]\n")},
+	}
+
+	assert.ErrorContains(t, Init(fs), `fr-FR/index.yml: key "%d items": plural category "one": expected a string`)
+}
+
+func TestInit_nestedKeys(t *testing.T) {
+	initLocales(t, map[string]string{
+		"en-GB": "",
+		"fr-FR": "menu:\n  home: Accueil\n  account:\n    settings: Paramètres\n    \"%d messages\":\n      one: \"%d message\"\n      other: \"%d messages\"\n",
+	})
+	fr := context.WithValue(context.Background(), contextKey{}, "fr-FR")
+
+	assert.Equal(t, "Accueil", Str(fr, "menu.home"))
+	assert.Equal(t, "Paramètres", Str(fr, "menu.account.settings"))
+	assert.Equal(t, "2 messages", Str(fr, "menu.account.%d messages", 2))
+	assert.Equal(t, "1 message", render(t, TrN(fr, "menu.account.%d messages", 1)))
+}
+
+func TestPluralCategories(t *testing.T) {
+	assert.Equal(t, []string{"one", "other"}, PluralCategories("en-GB"))
+	assert.Equal(t, []string{"one", "other"}, PluralCategories("fr-FR"))
+	assert.Equal(t, []string{"one", "few", "many", "other"}, PluralCategories("pl-PL"))
+	assert.Equal(t, []string{"zero", "one", "two", "few", "many", "other"}, PluralCategories("ar"))
+	assert.Equal(t, []string{"other"}, PluralCategories("ja-JP"))
+	assert.Equal(t, "few", PluralCategory("pl-PL", 22))
+}
@@ -0,0 +1,442 @@
+package ki18n
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/feature/plural"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"golang.org/x/text/number"
+)
+
+var ErrInvalidMessage = errors.New("invalid message")
+
+// Args are the named arguments of an ICU MessageFormat translation:
+//
+//	Tr(ctx, "{name} has {count, plural, one {# item} other {# items}}", ki18n.Args{"name": name, "count": count})
+type Args map[string]any
+
+// An icuNode is a part of a parsed message: icuText, icuArg or icuPound
+type icuNode any
+
+type icuText string
+
+// icuPound is the # of plural sub-messages, replaced by the formatted count
+type icuPound struct{}
+
+type icuOption struct {
+	selector string
+	message  []icuNode
+}
+
+// icuArg is a {name}, {name, type, style} or {name, type, options} argument
+type icuArg struct {
+	name    string
+	kind    string // "", number, date, time, plural, selectordinal or select
+	style   string
+	offset  int
+	options []icuOption
+}
+
+type icuParser struct {
+	runes []rune
+	pos   int
+}
+
+func parseICU(text string) ([]icuNode, error) {
+	p := &icuParser{runes: []rune(text)}
+	nodes, err := p.parseMessage(false)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w at %d in %q", ErrInvalidMessage, err, p.pos, text)
+	}
+	if p.pos < len(p.runes) {
+		return nil, fmt.Errorf("%w: unexpected } at %d in %q", ErrInvalidMessage, p.pos, text)
+	}
+	return nodes, nil
+}
+
+func (p *icuParser) peek() rune {
+	if p.pos < len(p.runes) {
+		return p.runes[p.pos]
+	}
+	return 0
+}
+
+func (p *icuParser) skipSpaces() {
+	for p.pos < len(p.runes) && unicode.IsSpace(p.runes[p.pos]) {
+		p.pos++
+	}
+}
+
+func (p *icuParser) readWord() string {
+	start := p.pos
+	for p.pos < len(p.runes) && (unicode.IsLetter(p.runes[p.pos]) || unicode.IsDigit(p.runes[p.pos]) || strings.ContainsRune("_=-:.", p.runes[p.pos])) {
+		p.pos++
+	}
+	return string(p.runes[start:p.pos])
+}
+
+func (p *icuParser) expect(char rune) error {
+	p.skipSpaces()
+	if p.peek() != char {
+		return fmt.Errorf("expected %q", char)
+	}
+	p.pos++
+	return nil
+}
+
+// parseMessage reads text and arguments until an unmatched } or the end.
+// Apostrophes quote special characters: '{' is a literal brace, and a doubled apostrophe is an apostrophe.
+func (p *icuParser) parseMessage(inPlural bool) ([]icuNode, error) {
+	nodes := []icuNode{}
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			nodes = append(nodes, icuText(text.String()))
+			text.Reset()
+		}
+	}
+	for p.pos < len(p.runes) {
+		char := p.runes[p.pos]
+		switch {
+		case char == '}':
+			flush()
+			return nodes, nil
+		case char == '{':
+			flush()
+			p.pos++
+			arg, err := p.parseArg(inPlural)
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, arg)
+		case char == '#' && inPlural:
+			flush()
+			p.pos++
+			nodes = append(nodes, icuPound{})
+		case char == '\'':
+			p.pos++
+			next := p.peek()
+			switch {
+			case next == '\'':
+				text.WriteRune('\'')
+				p.pos++
+			case next == '{' || next == '}' || (next == '#' && inPlural):
+				end := p.pos
+				for end < len(p.runes) && p.runes[end] != '\'' {
+					end++
+				}
+				text.WriteString(string(p.runes[p.pos:end]))
+				p.pos = min(end+1, len(p.runes))
+			default:
+				text.WriteRune('\'')
+			}
+		default:
+			text.WriteRune(char)
+			p.pos++
+		}
+	}
+	flush()
+	return nodes, nil
+}
+
+func (p *icuParser) parseArg(inPlural bool) (icuArg, error) {
+	p.skipSpaces()
+	arg := icuArg{name: p.readWord()}
+	if arg.name == "" {
+		return arg, errors.New("expected an argument name")
+	}
+	p.skipSpaces()
+	if p.peek() == '}' {
+		p.pos++
+		return arg, nil
+	}
+	if err := p.expect(','); err != nil {
+		return arg, err
+	}
+	p.skipSpaces()
+	arg.kind = p.readWord()
+	p.skipSpaces()
+	switch arg.kind {
+	case "number", "date", "time":
+		if p.peek() == ',' {
+			p.pos++
+			p.skipSpaces()
+			arg.style = p.readWord()
+		}
+		return arg, p.expect('}')
+	case "plural", "selectordinal", "select":
+		if err := p.expect(','); err != nil {
+			return arg, err
+		}
+		return arg, p.parseOptions(&arg, inPlural)
+	default:
+		return arg, fmt.Errorf("unknown argument type %q", arg.kind)
+	}
+}
+
+// parseOptions reads the sub-messages of a plural or select; # stays the plural count in nested selects
+func (p *icuParser) parseOptions(arg *icuArg, inPlural bool) error {
+	for {
+		p.skipSpaces()
+		if p.peek() == '}' {
+			p.pos++
+			break
+		}
+		selector := p.readWord()
+		if selector == "" {
+			return errors.New("expected a selector")
+		}
+		if offset, ok := strings.CutPrefix(selector, "offset:"); ok && arg.kind == "plural" {
+			n, err := strconv.Atoi(offset)
+			if err != nil {
+				return fmt.Errorf("bad offset %q", offset)
+			}
+			arg.offset = n
+			continue
+		}
+		if err := p.expect('{'); err != nil {
+			return err
+		}
+		message, err := p.parseMessage(inPlural || arg.kind != "select")
+		if err != nil {
+			return err
+		}
+		if err := p.expect('}'); err != nil {
+			return err
+		}
+		arg.options = append(arg.options, icuOption{selector: selector, message: message})
+	}
+	for _, option := range arg.options {
+		if option.selector == "other" {
+			return nil
+		}
+	}
+	return fmt.Errorf("argument %q has no other option", arg.name)
+}
+
+// MessageArgs returns the arguments of a message by name, with their type: the ICU argument type
+// ("" for a simple {name}), or the printf verb for positional args named "1", "2"...
+func MessageArgs(text string) (map[string]string, error) {
+	nodes, err := parseICU(text)
+	if err != nil {
+		return nil, err
+	}
+	args := map[string]string{}
+	collectArgs(nodes, args)
+	if len(args) > 0 {
+		return args, nil
+	}
+	return PrintfArgs(text), nil
+}
+
+func collectArgs(nodes []icuNode, args map[string]string) {
+	for _, node := range nodes {
+		if arg, ok := node.(icuArg); ok {
+			args[arg.name] = arg.kind
+			for _, option := range arg.options {
+				collectArgs(option.message, args)
+			}
+		}
+	}
+}
+
+// PrintfArgs returns the printf verbs of a format by position, named "1", "2"..., ignoring ICU braces
+func PrintfArgs(text string) map[string]string {
+	args := map[string]string{}
+	position := 1
+	for i := 0; i < len(text); i++ {
+		if text[i] != '%' {
+			continue
+		}
+		i++
+		// Flags, width, precision and explicit argument indexes, such as %-5.2f or %[2]s
+		for i < len(text) && strings.IndexByte("+-# 0123456789.*[]", text[i]) >= 0 {
+			if text[i] == '[' {
+				end := strings.IndexByte(text[i:], ']')
+				if n, err := strconv.Atoi(text[i+1 : i+max(end, 1)]); end > 0 && err == nil {
+					position = n
+				}
+			}
+			i++
+		}
+		if i < len(text) && text[i] != '%' {
+			args[strconv.Itoa(position)] = string(text[i])
+			position++
+		}
+	}
+	return args
+}
+
+type icuFormatter struct {
+	lang    string
+	locale  Locale
+	printer *message.Printer
+	args    Args
+	out     output
+}
+
+func formatICU(lang string, locale Locale, printer *message.Printer, text string, args Args, out output) (string, error) {
+	nodes, err := parseICU(text)
+	if err != nil {
+		return "", err
+	}
+	f := icuFormatter{lang: lang, locale: locale, printer: printer, args: args, out: out}
+	var builder strings.Builder
+	if err := f.format(&builder, nodes, nil); err != nil {
+		return "", err
+	}
+	return builder.String(), nil
+}
+
+// format writes the nodes, pound is the count of the enclosing plural argument
+func (f icuFormatter) format(builder *strings.Builder, nodes []icuNode, pound any) error {
+	for _, node := range nodes {
+		switch node := node.(type) {
+		case icuText:
+			builder.WriteString(f.out.escapeText(string(node)))
+		case icuPound:
+			builder.WriteString(f.printer.Sprint(number.Decimal(pound)))
+		case icuArg:
+			value, ok := f.args[node.name]
+			if !ok {
+				return fmt.Errorf("%w: missing argument %q", ErrInvalidMessage, node.name)
+			}
+			if err := f.formatArg(builder, node, value, pound); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (f icuFormatter) formatArg(builder *strings.Builder, arg icuArg, value any, pound any) error {
+	switch arg.kind {
+	case "":
+		builder.WriteString(f.out.escapeArg(f.printer.Sprint(value)))
+	case "number":
+		if _, ok := toFloat(value); !ok {
+			return fmt.Errorf("%w: argument %q is not a number", ErrInvalidMessage, arg.name)
+		}
+		switch arg.style {
+		case "":
+			builder.WriteString(f.printer.Sprint(number.Decimal(value)))
+		case "integer":
+			builder.WriteString(f.printer.Sprint(number.Decimal(value, number.MaxFractionDigits(0))))
+		case "percent":
+			builder.WriteString(f.printer.Sprint(number.Percent(value)))
+		default:
+			return fmt.Errorf("%w: unknown number style %q", ErrInvalidMessage, arg.style)
+		}
+	case "date", "time":
+		t, ok := value.(time.Time)
+		if !ok {
+			return fmt.Errorf("%w: argument %q is not a time", ErrInvalidMessage, arg.name)
+		}
+		switch style := Style(arg.style); style {
+		case "", StyleShort, StyleMedium, StyleLong, StyleFull:
+			builder.WriteString(formatTimeStyle(f.locale, arg.kind, style, t))
+		default:
+			return fmt.Errorf("%w: unknown %s style %q", ErrInvalidMessage, arg.kind, arg.style)
+		}
+	case "select":
+		return f.format(builder, selectOption(arg.options, fmt.Sprint(value)), pound)
+	case "plural", "selectordinal":
+		n, ok := toFloat(value)
+		if !ok {
+			return fmt.Errorf("%w: argument %q is not a number", ErrInvalidMessage, arg.name)
+		}
+		rules := plural.Cardinal
+		if arg.kind == "selectordinal" {
+			rules = plural.Ordinal
+		}
+		// Exact selectors match the value, categories match the value minus the offset
+		if option, ok := exactOption(arg.options, n); ok {
+			return f.format(builder, option, n-float64(arg.offset))
+		}
+		category := formCategories[matchPlural(rules, pluralTag(f.lang), n-float64(arg.offset))]
+		return f.format(builder, selectOption(arg.options, category), n-float64(arg.offset))
+	}
+	return nil
+}
+
+func selectOption(options []icuOption, selector string) []icuNode {
+	var other []icuNode
+	for _, option := range options {
+		if option.selector == selector {
+			return option.message
+		}
+		if option.selector == "other" {
+			other = option.message
+		}
+	}
+	return other
+}
+
+func exactOption(options []icuOption, n float64) ([]icuNode, bool) {
+	for _, option := range options {
+		if exact, ok := strings.CutPrefix(option.selector, "="); ok {
+			if value, err := strconv.ParseFloat(exact, 64); err == nil && value == n {
+				return option.message, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// formatTimeStyle formats date args with FormatDate and time args with FormatTimeOfDay, in medium style by default
+func formatTimeStyle(locale Locale, kind string, style Style, t time.Time) string {
+	if style == "" {
+		style = StyleMedium
+	}
+	if kind == "time" {
+		return locale.FormatTimeOfDay(t, style)
+	}
+	return locale.FormatDate(t, style)
+}
+
+func toFloat(value any) (float64, bool) {
+	switch value := value.(type) {
+	case int:
+		return float64(value), true
+	case int8:
+		return float64(value), true
+	case int16:
+		return float64(value), true
+	case int32:
+		return float64(value), true
+	case int64:
+		return float64(value), true
+	case uint:
+		return float64(value), true
+	case uint8:
+		return float64(value), true
+	case uint16:
+		return float64(value), true
+	case uint32:
+		return float64(value), true
+	case uint64:
+		return float64(value), true
+	case float32:
+		return float64(value), true
+	case float64:
+		return value, true
+	}
+	return 0, false
+}
+
+// matchPlural computes the CLDR operands of n: integer digits, and visible fraction digits with and without trailing zeros
+func matchPlural(rules *plural.Rules, tag language.Tag, n float64) plural.Form {
+	n = math.Abs(n)
+	formatted := strconv.FormatFloat(n, 'f', -1, 64)
+	integer, fraction, _ := strings.Cut(formatted, ".")
+	i, _ := strconv.Atoi(integer)
+	f, _ := strconv.Atoi(fraction)
+	return rules.MatchPlural(tag, i, len(fraction), len(fraction), f, f)
+}
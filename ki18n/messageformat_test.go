@@ -0,0 +1,73 @@
+package ki18n
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTr_messageFormat(t *testing.T) {
+	initLocales(t, map[string]string{
+		"fr-FR": `"{name} has {count, plural, =0 {no items} one {# item} other {# items}}": "{name} a {count, plural, =0 {aucun article} one {# article} other {# articles}}"
+"{place, selectordinal, one {#st} two {#nd} few {#rd} other {#th}} place": "{place, selectordinal, one {#re} other {#e}} place"
+"{gender, select, female {She} male {He} other {They}} replied": "{gender, select, female {Elle a} male {Il a} other {Iel a}} répondu"
+`,
+	})
+	en := context.WithValue(context.Background(), contextKey{}, "en-GB")
+	fr := context.WithValue(context.Background(), contextKey{}, "fr-FR")
+	items := "{name} has {count, plural, =0 {no items} one {# item} other {# items}}"
+	place := "{place, selectordinal, one {#st} two {#nd} few {#rd} other {#th}} place"
+
+	assert.Equal(t, "Ann has no items", render(t, Tr(en, items, Args{"name": "Ann", "count": 0})))
+	assert.Equal(t, "Ann has 1 item", render(t, Tr(en, items, Args{"name": "Ann", "count": 1})))
+	assert.Equal(t, "Ann has 1,234 items", render(t, Tr(en, items, Args{"name": "Ann", "count": 1234})))
+	assert.Equal(t, "Ann a 1\u00a0234 articles", render(t, Tr(fr, items, Args{"name": "Ann", "count": 1234})))
+	assert.Equal(t, "Ann a 1,5 article", render(t, Tr(fr, items, Args{"name": "Ann", "count": 1.5})), "decimal counts use CLDR operands")
+	assert.Equal(t, "22nd place", render(t, Tr(en, place, Args{"place": 22})))
+	assert.Equal(t, "13th place", render(t, Tr(en, place, Args{"place": 13})))
+	assert.Equal(t, "1re place", render(t, Tr(fr, place, Args{"place": 1})))
+	assert.Equal(t, "Elle a répondu", render(t, Tr(fr, "{gender, select, female {She} male {He} other {They}} replied", Args{"gender": "female"})))
+	assert.Equal(t, "Iel a répondu", render(t, Tr(fr, "{gender, select, female {She} male {He} other {They}} replied", Args{"gender": "unknown"})))
+}
+
+func TestTr_messageFormatArgs(t *testing.T) {
+	initLocales(t, map[string]string{})
+	en := context.WithValue(context.Background(), contextKey{}, "en-GB")
+	fr := context.WithValue(context.Background(), contextKey{}, "fr-FR")
+	d := time.Date(2026, time.May, 12, 14, 30, 0, 0, time.UTC)
+
+	assert.Equal(t, "Total: 1,234.5", render(t, Tr(en, "Total: {total, number}", Args{"total": 1234.5})))
+	assert.Equal(t, "Total: 1\u00a0235", render(t, Tr(fr, "Total: {total, number, integer}", Args{"total": 1234.6})))
+	assert.Equal(t, "Done: 25%", render(t, Tr(en, "Done: {ratio, number, percent}", Args{"ratio": 0.25})))
+	assert.Equal(t, "Due 12 mai 2026 at 14:30", render(t, Tr(fr, "Due {due, date} at {due, time, short}", Args{"due": d})))
+	assert.Equal(t, "You and 2 others", render(t, Tr(en, "You{guests, plural, offset:1 =0 {} =1 { and a guest} one { and # other} other { and # others}}", Args{"guests": 3})))
+	assert.Equal(t, "It's {name}", Str(en, "It''s '{name}'", Args{}))
+	assert.Equal(t, "It&#39;s {name}", render(t, Tr(en, "It''s '{name}'")), "quotes are unescaped without args")
+	assert.Equal(t, "Don't", Str(en, "Don't"))
+	assert.Equal(t, "Total: {total, number}", render(t, Tr(en, "Total: {total, number}", Args{"total": "12"})), "non-numbers are invalid")
+	assert.Equal(t, "{broken", render(t, Tr(en, "{broken", Args{"broken": 1})), "invalid messages are shown as is")
+	assert.Equal(t, "Hello {name}", render(t, Tr(en, "Hello {name}", Args{})), "messages with missing args are shown as is")
+}
+
+func TestMessageArgs(t *testing.T) {
+	args, err := MessageArgs("{name} has {count, plural, one {# {kind, select, other {item}}} other {# items}} since {since, date, short}")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"name": "", "count": "plural", "kind": "select", "since": "date"}, args)
+
+	args, err = MessageArgs("%d items in %s, 100%%")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"1": "d", "2": "s"}, args)
+
+	args, err = MessageArgs("%[2]s then %[1]d")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"2": "s", "1": "d"}, args)
+
+	_, err = MessageArgs("{count, plural, one {# item}}")
+	assert.ErrorIs(t, err, ErrInvalidMessage)
+	assert.ErrorContains(t, err, `argument "count" has no other option`)
+
+	_, err = MessageArgs("{count, currency}")
+	assert.ErrorContains(t, err, `unknown argument type "currency"`)
+}
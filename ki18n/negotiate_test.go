@@ -0,0 +1,79 @@
+package ki18n
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiate(t *testing.T) {
+	assert.NoError(t, Init(fstest.MapFS{}, Locale{Lang: "es-ES"}))
+
+	assert.Equal(t, "fr-FR", Negotiate("fr-CA"))
+	assert.Equal(t, "en-GB", Negotiate("en-US"))
+	assert.Equal(t, "es-ES", Negotiate("es-MX"))
+	assert.Equal(t, "", Negotiate("de-DE"))
+	assert.Equal(t, "", Negotiate("not a language"))
+	assert.Equal(t, "fr-FR", Negotiate("de-DE, fr;q=0.9, en;q=0.8"), "quality weights order the preferences")
+	assert.Equal(t, "en-GB", Negotiate("fr;q=0, en-US;q=0.5"), "q=0 excludes a language")
+}
+
+func TestLangMiddleware_negotiatesStrategies(t *testing.T) {
+	initLocales(t, map[string]string{})
+	var captured []string
+	handler := LangMiddleware(CookieStrategy("lang"), AcceptLanguageStrategy)(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		captured = append(captured, langFromContext(r.Context()))
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: "lang", Value: "de-DE"})
+	r.Header.Set("Accept-Language", "fr-CA,fr;q=0.9,en;q=0.5")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: "lang", Value: "<script>"})
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	assert.Equal(t, []string{"fr-FR", "en-GB"}, captured, "unsupported cookie values are ignored")
+}
+
+func TestInit_fallbacks(t *testing.T) {
+	fs := fstest.MapFS{
+		"en-GB/index.yml": &fstest.MapFile{Data: []byte("Color: Color\nTrash: Trash\n")},
+		"fr-FR/index.yml": &fstest.MapFile{Data: []byte("Color: Couleur\nTrash: Corbeille\n")},
+		"fr-CA/index.yml": &fstest.MapFile{Data: []byte("Trash: Poubelle\n")},
+	}
+	assert.NoError(t, Init(fs, Locale{Lang: "fr-CA", Fallbacks: []string{"fr-FR"}}))
+	ctx := context.WithValue(context.Background(), contextKey{}, "fr-CA")
+
+	assert.Equal(t, "Poubelle", render(t, Tr(ctx, "Trash")))
+	assert.Equal(t, "Couleur", render(t, Tr(ctx, "Color")))
+	assert.Equal(t, "fr-CA", Negotiate("fr-CA"))
+
+	assert.ErrorIs(t, Init(fs, Locale{Lang: "fr-CA", Fallbacks: []string{"fr-BE"}}), ErrUnregisteredLang)
+}
+
+func TestInit_defaultLang(t *testing.T) {
+	previous := defaultTranslator.Load()
+	DefaultLang = "fr-FR"
+	t.Cleanup(func() {
+		// The default translator of other tests has en-GB as default language
+		DefaultLang = "en-GB"
+		defaultTranslator.Store(previous)
+	})
+	initLocales(t, map[string]string{"fr-FR": "Hello: Bonjour\n"})
+	var lang string
+	handler := LangMiddleware(AcceptLanguageStrategy)(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		lang = langFromContext(r.Context())
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, "fr-FR", lang)
+	assert.Equal(t, "Bonjour", render(t, Tr(context.WithValue(context.Background(), contextKey{}, "en-GB"), "Hello")))
+
+	DefaultLang = "de-DE"
+	assert.ErrorContains(t, Init(fstest.MapFS{}), "language not registered: default language de-DE")
+}
@@ -0,0 +1,53 @@
+package ki18n
+
+import (
+	"golang.org/x/text/feature/plural"
+	"golang.org/x/text/language"
+)
+
+// CLDR plural categories, in the usual order of translation files
+var cldrCategories = []string{"zero", "one", "two", "few", "many", "other"}
+
+var formCategories = map[plural.Form]string{
+	plural.Zero:  "zero",
+	plural.One:   "one",
+	plural.Two:   "two",
+	plural.Few:   "few",
+	plural.Many:  "many",
+	plural.Other: "other",
+}
+
+// Counts are probed up to this value to find the categories used by a language, which covers all CLDR rules
+const pluralProbeLimit = 1000
+
+func pluralTag(lang string) language.Tag {
+	tag, err := language.Parse(lang)
+	if err != nil {
+		return language.Und
+	}
+	return tag
+}
+
+// PluralCategory returns the CLDR plural category of an integer count in lang, such as "one" or "other"
+func PluralCategory(lang string, count int) string {
+	if count < 0 {
+		count = -count
+	}
+	return formCategories[plural.Cardinal.MatchPlural(pluralTag(lang), count, 0, 0, 0, 0)]
+}
+
+// PluralCategories returns the categories that translations in lang must define for integer counts.
+// It always contains other, the fallback of missing forms.
+func PluralCategories(lang string) []string {
+	used := map[string]bool{"other": true}
+	for count := range pluralProbeLimit {
+		used[PluralCategory(lang, count)] = true
+	}
+	categories := []string{}
+	for _, category := range cldrCategories {
+		if used[category] {
+			categories = append(categories, category)
+		}
+	}
+	return categories
+}
@@ -0,0 +1,65 @@
+package ki18n
+
+import (
+	"context"
+
+	"github.com/a-h/templ"
+)
+
+// A Scope translates keys of a namespace, the <lang>/<namespace>.yml locale files, in a message context telling apart
+// identical source strings. The zero Scope translates keys of DefaultNamespace without context, like Tr.
+//
+//	@ki18n.Namespace("billing").Context("verb").Tr(ctx, "Close")
+type Scope struct {
+	namespace string
+	msgctxt   string
+}
+
+// Namespace returns the scope of the keys of <lang>/<name>.yml, such as the locale file of a feature module
+func Namespace(name string) Scope {
+	return Scope{namespace: name}
+}
+
+// Context returns the scope of the keys with a message context, such as Context("verb") for the "Close" button
+func Context(msgctxt string) Scope {
+	return Scope{msgctxt: msgctxt}
+}
+
+// Namespace returns the scope of the keys of <lang>/<name>.yml, in the context of s
+func (s Scope) Namespace(name string) Scope {
+	s.namespace = name
+	return s
+}
+
+// Context returns the scope of the keys with a message context, in the namespace of s
+func (s Scope) Context(msgctxt string) Scope {
+	s.msgctxt = msgctxt
+	return s
+}
+
+func (s Scope) namespaceName() string {
+	if s.namespace == "" {
+		return DefaultNamespace
+	}
+	return s.namespace
+}
+
+// Tr translates a key of the scope, escaped like the package Tr
+func (s Scope) Tr(ctx context.Context, key string, args ...any) templ.Component {
+	return templ.Raw(translatorFrom(ctx).translate(langFromContext(ctx), s, key, argsInZone(ctx, args), htmlOutput))
+}
+
+// TrHTML translates a key of the scope written in trusted HTML, escaped like the package TrHTML
+func (s Scope) TrHTML(ctx context.Context, key string, args ...any) templ.Component {
+	return templ.Raw(translatorFrom(ctx).translate(langFromContext(ctx), s, key, argsInZone(ctx, args), trustedOutput))
+}
+
+// TrN translates a key of the scope with plural forms, like the package TrN
+func (s Scope) TrN(ctx context.Context, key string, count int, args ...any) templ.Component {
+	return templ.Raw(translatorFrom(ctx).translateN(langFromContext(ctx), s, key, count, argsInZone(ctx, args), htmlOutput))
+}
+
+// Str translates a key of the scope to a plain string, like the package Str
+func (s Scope) Str(ctx context.Context, key string, args ...any) string {
+	return translatorFrom(ctx).translate(langFromContext(ctx), s, key, argsInZone(ctx, args), plainOutput)
+}
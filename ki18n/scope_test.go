@@ -0,0 +1,55 @@
+package ki18n
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScope_namespaces(t *testing.T) {
+	assert.NoError(t, Init(fstest.MapFS{
+		"fr-FR/index.yml":   {Data: []byte("Save: Enregistrer\n")},
+		"fr-FR/billing.yml": {Data: []byte("Save: Payer\n\"%d invoices\":\n  one: \"%d facture\"\n  other: \"%d factures\"\n")},
+	}))
+	ctx := context.WithValue(context.Background(), contextKey{}, "fr-FR")
+	billing := Namespace("billing")
+
+	assert.Equal(t, "Enregistrer", render(t, Tr(ctx, "Save")))
+	assert.Equal(t, "Enregistrer", render(t, Namespace(DefaultNamespace).Tr(ctx, "Save")))
+	assert.Equal(t, "Payer", render(t, billing.Tr(ctx, "Save")))
+	assert.Equal(t, "2 factures", render(t, billing.TrN(ctx, "%d invoices", 2)))
+	assert.Equal(t, "%d invoices", Str(ctx, "%d invoices"), "namespaces do not share keys")
+	assert.Equal(t, "Cancel", Namespace("unknown").Str(ctx, "Cancel"))
+}
+
+func TestScope_contexts(t *testing.T) {
+	assert.NoError(t, Init(fstest.MapFS{
+		"fr-FR/index.yml": {Data: []byte(`Close: Fermer
+"@adjective":
+  Close: Proche
+  "%d days":
+    one: "%d jour proche"
+    other: "%d jours proches"
+`)},
+		"fr-FR/billing.yml": {Data: []byte("\"@verb\":\n  Close: Clôturer\n")},
+	}))
+	ctx := context.WithValue(context.Background(), contextKey{}, "fr-FR")
+
+	assert.Equal(t, "Fermer", Str(ctx, "Close"))
+	assert.Equal(t, "Proche", Context("adjective").Str(ctx, "Close"))
+	assert.Equal(t, "3 jours proches", render(t, Context("adjective").TrN(ctx, "%d days", 3)))
+	assert.Equal(t, "Clôturer", Namespace("billing").Context("verb").Str(ctx, "Close"))
+	assert.Equal(t, "Clôturer", Context("verb").Namespace("billing").Str(ctx, "Close"))
+	assert.Equal(t, "Close", Context("verb").Str(ctx, "Close"), "contexts do not fall back to the key without context")
+}
+
+func TestParseTranslations_contextErrors(t *testing.T) {
+	err := parseTranslations([]byte("\"@verb\":\n  Close:\n    other: [Fermer]\n"), map[string]translation{})
+	assert.EqualError(t, err, `context "verb": key "Close": plural category "other": expected a string`)
+
+	translations := map[string]translation{}
+	assert.NoError(t, parseTranslations([]byte("\"@me\": Moi\n"), translations))
+	assert.Equal(t, translation{text: "Moi"}, translations["@me"], "@ keys with a text are not contexts")
+}
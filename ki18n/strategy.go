@@ -0,0 +1,206 @@
+package ki18n
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// A VaryStrategy detects the language from request headers, which responses must list in their Vary header
+type VaryStrategy interface {
+	Strategy
+	Vary() []string
+}
+
+func (cookieStrategy) Vary() []string { return []string{"Cookie"} }
+
+func (acceptLanguageStrategy) Vary() []string { return []string{"Accept-Language"} }
+
+type queryStrategy struct{ name string }
+
+func (s queryStrategy) Detect(r *http.Request) string {
+	return r.URL.Query().Get(s.name)
+}
+
+// QueryStrategy reads the named query parameter, such as ?lang=fr
+func QueryStrategy(name string) Strategy {
+	return queryStrategy{name: name}
+}
+
+type subdomainStrategy struct{}
+
+func (subdomainStrategy) Detect(r *http.Request) string {
+	label, _, ok := strings.Cut(r.Host, ".")
+	if !ok {
+		return ""
+	}
+	return label
+}
+
+// SubdomainStrategy reads the first label of the host, such as fr.example.com
+var SubdomainStrategy Strategy = subdomainStrategy{}
+
+type pathPrefixStrategy struct{}
+
+func (pathPrefixStrategy) Detect(r *http.Request) string {
+	lang, _ := translatorFrom(r.Context()).splitLangPrefix(r.URL.Path)
+	return lang
+}
+
+// PathPrefixStrategy reads the first segment of the path, such as /fr/items. Mount the routes with StripLangPrefix.
+var PathPrefixStrategy Strategy = pathPrefixStrategy{}
+
+// A LangPreferrer is a user with a saved language preference
+type LangPreferrer interface {
+	PreferredLang() string
+}
+
+type userStrategy[U any] struct {
+	user func(ctx context.Context) (U, bool)
+}
+
+func (s userStrategy[U]) Detect(r *http.Request) string {
+	user, ok := s.user(r.Context())
+	if !ok {
+		return ""
+	}
+	preferrer, ok := any(user).(LangPreferrer)
+	if !ok {
+		return ""
+	}
+	return preferrer.PreferredLang()
+}
+
+// The user is identified by the session cookie
+func (userStrategy[U]) Vary() []string { return []string{"Cookie"} }
+
+// UserStrategy reads the saved preference of the authenticated user, when the user type implements LangPreferrer:
+//
+//	ki18n.UserStrategy(backend.User)
+func UserStrategy[U any](user func(ctx context.Context) (U, bool)) Strategy {
+	return userStrategy[U]{user: user}
+}
+
+// langPrefix is the path prefix of a registered language: its base language, such as fr for fr-FR,
+// or the lowercase tag when several registered languages share the base, such as fr-ca
+func (t *Translator) langPrefix(lang string) string {
+	base := languageBase(lang)
+	for _, other := range t.supported {
+		if other != lang && languageBase(other) == base {
+			return strings.ToLower(lang)
+		}
+	}
+	return base
+}
+
+// splitLangPrefix returns the registered language of the first path segment and the rest of the path
+func (t *Translator) splitLangPrefix(path string) (string, string) {
+	segment, rest, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	for _, lang := range t.supported {
+		if segment == t.langPrefix(lang) {
+			return lang, "/" + rest
+		}
+	}
+	return "", path
+}
+
+// StripLangPrefix serves the path without its language prefix, such as /items for /fr/items
+func StripLangPrefix(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lang, rest := translatorFrom(r.Context()).splitLangPrefix(r.URL.Path)
+		if lang == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		r2 := r.Clone(r.Context())
+		r2.URL.Path, r2.URL.RawPath = rest, ""
+		next.ServeHTTP(w, r2)
+	})
+}
+
+// LangPath prefixes a path with a language, replacing its current prefix: LangPath("fr-FR", "/en/items") is /fr/items
+func (t *Translator) LangPath(lang string, path string) string {
+	_, rest := t.splitLangPrefix(path)
+	if rest == "/" {
+		return "/" + t.langPrefix(lang) + "/"
+	}
+	return "/" + t.langPrefix(lang) + rest
+}
+
+// LangPath prefixes a path with a language of the default translator, see Translator.LangPath
+func LangPath(lang string, path string) string {
+	return defaultTranslator.Load().LangPath(lang, path)
+}
+
+// LocalizedPath prefixes a path with the language of the context, for links with PathPrefixStrategy
+func LocalizedPath(ctx context.Context, path string) string {
+	return translatorFrom(ctx).LangPath(langFromContext(ctx), path)
+}
+
+// switchRedirect returns the local path to go back to after switching languages, from the redirect form value or the
+// Referer header, with its language prefix replaced
+func (t *Translator) switchRedirect(r *http.Request, lang string) string {
+	target := r.FormValue("redirect")
+	if target == "" {
+		referer, err := url.Parse(r.Referer())
+		if err != nil || referer.Host != r.Host {
+			return "/"
+		}
+		target = referer.RequestURI()
+	}
+	// Only local paths, as //host and /\host are other hosts for browsers, which also strip control and space
+	// characters, such as the tab of /\t/host
+	if strings.ContainsFunc(target, func(char rune) bool { return char <= ' ' || char == 0x7f }) {
+		return "/"
+	}
+	u, err := url.Parse(target)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Opaque != "" || !strings.HasPrefix(u.Path, "/") ||
+		strings.HasPrefix(u.Path, "//") || strings.HasPrefix(u.Path, "/\\") {
+		return "/"
+	}
+	path := u.Path
+	if prefixed, _ := t.splitLangPrefix(path); prefixed != "" {
+		path = t.LangPath(lang, path)
+	}
+	return (&url.URL{Path: path, RawQuery: u.RawQuery}).String()
+}
+
+// SwitchLangHandler saves the lang form value in the named cookie, for CookieStrategy, and redirects back
+func SwitchLangHandler(cookieName string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t := translatorFrom(r.Context())
+		lang := t.Negotiate(r.FormValue("lang"))
+		if lang == "" {
+			http.Error(w, "unsupported language", http.StatusBadRequest)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     cookieName,
+			Value:    lang,
+			Path:     "/",
+			MaxAge:   int((365 * 24 * time.Hour).Seconds()),
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+		http.Redirect(w, r, t.switchRedirect(r, lang), http.StatusSeeOther)
+	})
+}
+
+// setLangHeaders declares the language of the response, and the request headers it depends on for caches
+func setLangHeaders(w http.ResponseWriter, lang string, strategies []Strategy) {
+	w.Header().Set("Content-Language", lang)
+	for _, s := range strategies {
+		vary, ok := s.(VaryStrategy)
+		if !ok {
+			continue
+		}
+		for _, header := range vary.Vary() {
+			if !slices.Contains(w.Header().Values("Vary"), header) {
+				w.Header().Add("Vary", header)
+			}
+		}
+	}
+}
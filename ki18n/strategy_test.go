@@ -0,0 +1,99 @@
+package ki18n
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type preferringUser struct{ lang string }
+
+func (u preferringUser) PreferredLang() string { return u.lang }
+
+func TestStrategies(t *testing.T) {
+	initLocales(t, map[string]string{})
+	r := httptest.NewRequest(http.MethodGet, "http://fr.example.com/de/items?lang=en", nil)
+	user := UserStrategy(func(ctx context.Context) (preferringUser, bool) { return preferringUser{lang: "fr-FR"}, true })
+	anonymous := UserStrategy(func(ctx context.Context) (preferringUser, bool) { return preferringUser{}, false })
+
+	assert.Equal(t, "en", QueryStrategy("lang").Detect(r))
+	assert.Equal(t, "fr", SubdomainStrategy.Detect(r))
+	assert.Equal(t, "", PathPrefixStrategy.Detect(r), "de is not registered")
+	assert.Equal(t, "fr-FR", PathPrefixStrategy.Detect(httptest.NewRequest(http.MethodGet, "/fr/items", nil)))
+	assert.Equal(t, "fr-FR", user.Detect(r))
+	assert.Equal(t, "", anonymous.Detect(r))
+}
+
+func TestLangMiddleware_headers(t *testing.T) {
+	initLocales(t, map[string]string{})
+	handler := LangMiddleware(QueryStrategy("lang"), CookieStrategy("lang"), AcceptLanguageStrategy)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?lang=fr", nil))
+	assert.Equal(t, "fr-FR", w.Header().Get("Content-Language"))
+	assert.Equal(t, []string{"Cookie", "Accept-Language"}, w.Header().Values("Vary"))
+}
+
+func TestStripLangPrefix(t *testing.T) {
+	assert.NoError(t, Init(fstest.MapFS{}, Locale{Lang: "fr-CA", Fallbacks: []string{"fr-FR"}}))
+	var path, lang string
+	handler := LangMiddleware(PathPrefixStrategy)(StripLangPrefix(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		path, lang = r.URL.Path, langFromContext(r.Context())
+	})))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fr-ca/items/1", nil))
+	assert.Equal(t, "/items/1", path)
+	assert.Equal(t, "fr-CA", lang)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/en/", nil))
+	assert.Equal(t, "/", path)
+	assert.Equal(t, "en-GB", lang)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/items", nil))
+	assert.Equal(t, "/items", path)
+
+	ctx := context.WithValue(context.Background(), contextKey{}, "fr-CA")
+	assert.Equal(t, "/fr-ca/items", LocalizedPath(ctx, "/items"))
+	assert.Equal(t, "/fr-fr/items", LangPath("fr-FR", "/fr-ca/items"), "languages sharing a base use their tag")
+	assert.Equal(t, "/en/", LangPath("en-GB", "/"))
+}
+
+func TestSwitchLangHandler(t *testing.T) {
+	initLocales(t, map[string]string{})
+	handler := SwitchLangHandler("lang")
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/lang", strings.NewReader(url.Values{"lang": {"fr-CA"}}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("Referer", "http://example.com/en/items?page=2")
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, "/fr/items?page=2", w.Header().Get("Location"))
+	assert.Equal(t, "fr-FR", w.Result().Cookies()[0].Value)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/lang?lang=en&redirect=//evil.com", nil))
+	assert.Equal(t, "/", w.Header().Get("Location"), "only local paths are followed")
+	for _, redirect := range []string{"/\t/evil.com", "/\\evil.com", "/%2F/evil.com", "/%5Cevil.com", "https://evil.com/", "/\n/evil.com", "/ /evil.com"} {
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/lang?"+url.Values{"lang": {"en"}, "redirect": {redirect}}.Encode(), nil))
+		assert.Equal(t, "/", w.Header().Get("Location"), "redirect %q", redirect)
+	}
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/lang?"+url.Values{"lang": {"en"}, "redirect": {"/fr/items/a%20b?q=1"}}.Encode(), nil))
+	assert.Equal(t, "/en/items/a%20b?q=1", w.Header().Get("Location"))
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/lang?lang=en", nil)
+	r.Header.Set("Referer", "https://evil.com/items")
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, "/", w.Header().Get("Location"))
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/lang?lang=xx", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,238 @@
+package ki18n
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/exp/slog"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// A Translator owns the translations, locales and formats of a set of languages.
+// Its LangMiddleware puts it in the request context, where Tr and the Format functions find it.
+type Translator struct {
+	Now func() time.Time // injectable time provider
+
+	defaultLang string
+	// translations are swapped by Reload while requests translate
+	translations atomic.Pointer[catalog]
+	locales      map[string]Locale
+	printers     map[string]*message.Printer
+	// supported are the registered languages, in the order of the matcher
+	supported []string
+	matcher   language.Matcher
+}
+
+// NewTranslator registers the built-in and extra locales, and reads their <lang>/<namespace>.yml translations from localesFS if not nil.
+// The default language is used when no strategy negotiates a registered language, and is the last fallback of translations.
+func NewTranslator(localesFS fs.FS, defaultLang string, extra ...Locale) (*Translator, error) {
+	all := append(defaultLocales, extra...)
+	t := &Translator{
+		defaultLang: defaultLang,
+		locales:     make(map[string]Locale, len(all)),
+		printers:    make(map[string]*message.Printer, len(all)),
+		supported:   make([]string, len(all)),
+	}
+
+	tags := make([]language.Tag, len(all))
+	for i, loc := range all {
+		t.supported[i] = loc.Lang
+		tags[i] = pluralTag(loc.Lang)
+		t.printers[loc.Lang] = message.NewPrinter(tags[i])
+		loc = withDefaultTimeFormats(loc, t.locales[defaultLocales[0].Lang])
+		t.locales[loc.Lang] = withDefaultFormats(loc, t.printers[loc.Lang])
+	}
+	t.matcher = language.NewMatcher(tags)
+	if _, ok := t.locales[defaultLang]; !ok {
+		return nil, fmt.Errorf("%w: default language %s", ErrUnregisteredLang, defaultLang)
+	}
+	for _, loc := range all {
+		for _, lang := range loc.Fallbacks {
+			if _, ok := t.locales[lang]; !ok {
+				return nil, fmt.Errorf("%w: %s, fallback of %s", ErrUnregisteredLang, lang, loc.Lang)
+			}
+		}
+	}
+
+	if err := t.Reload(localesFS); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Reload reads the <lang>/<namespace>.yml translations from localesFS again, keeping the current translations on error
+func (t *Translator) Reload(localesFS fs.FS) error {
+	c, err := loadCatalog(localesFS, t.supported)
+	if err != nil {
+		return err
+	}
+	t.translations.Store(&c)
+	return nil
+}
+
+// defaultTranslator serves the package functions outside of a Translator middleware, and is replaced by Init
+var defaultTranslator atomic.Pointer[Translator]
+
+func init() {
+	t, err := NewTranslator(nil, DefaultLang)
+	if err != nil {
+		panic(err)
+	}
+	defaultTranslator.Store(t)
+}
+
+type translatorContextKey struct{}
+
+// WithTranslator sets the translator used by Tr and the Format functions, such as for emails sent from jobs
+func WithTranslator(ctx context.Context, t *Translator) context.Context {
+	return context.WithValue(ctx, translatorContextKey{}, t)
+}
+
+func translatorFrom(ctx context.Context) *Translator {
+	if t, ok := ctx.Value(translatorContextKey{}).(*Translator); ok {
+		return t
+	}
+	return defaultTranslator.Load()
+}
+
+func langFromContext(ctx context.Context) string {
+	lang, _ := ctx.Value(contextKey{}).(string)
+	if lang == "" {
+		lang = translatorFrom(ctx).defaultLang
+	}
+	return lang
+}
+
+// localeFrom returns the locale of the language of the context
+func localeFrom(ctx context.Context) Locale {
+	return translatorFrom(ctx).locale(langFromContext(ctx))
+}
+
+// LangMiddleware puts the translator in the context, with the language of the first strategy detecting a registered
+// language, negotiated with Negotiate
+func (t *Translator) LangMiddleware(strategies ...Strategy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t.serveLang(w, r, next, strategies)
+		})
+	}
+}
+
+func (t *Translator) serveLang(w http.ResponseWriter, r *http.Request, next http.Handler, strategies []Strategy) {
+	// Strategies negotiate with the translator of the request
+	r = r.WithContext(WithTranslator(r.Context(), t))
+	lang := ""
+	for _, s := range strategies {
+		if l := t.Negotiate(s.Detect(r)); l != "" {
+			lang = l
+			break
+		}
+	}
+	if lang == "" {
+		lang = t.defaultLang
+	}
+	setLangHeaders(w, lang, strategies)
+	ctx := context.WithValue(r.Context(), contextKey{}, lang)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// Negotiate returns the registered language best matching a BCP-47 tag or an Accept-Language header with quality
+// weights, such as fr-FR for "fr-CA" and en-GB for "de-DE, en-US;q=0.8", or "" when none matches
+func (t *Translator) Negotiate(value string) string {
+	if value == "" {
+		return ""
+	}
+	tags, _, err := language.ParseAcceptLanguage(value)
+	if err != nil || len(tags) == 0 {
+		return ""
+	}
+	_, index, confidence := t.matcher.Match(tags...)
+	if confidence == language.No {
+		return ""
+	}
+	return t.supported[index]
+}
+
+func (t *Translator) locale(lang string) Locale {
+	loc, ok := t.locales[lang]
+	if !ok {
+		loc = t.locales[t.defaultLang]
+	}
+	return loc
+}
+
+func (t *Translator) now() time.Time {
+	if t.Now != nil {
+		return t.Now()
+	}
+	return Now()
+}
+
+// lookup returns the translation of key in the scope and lang, falling back to the fallbacks of its locale, then the
+// default language
+func (t *Translator) lookup(lang string, s Scope, key string) (translation, string, bool) {
+	namespace, key := s.namespaceName(), MessageKey(s.msgctxt, key)
+	translations := *t.translations.Load()
+	chain := append([]string{lang}, t.locales[lang].Fallbacks...)
+	for _, l := range append(chain, t.defaultLang) {
+		if tr, ok := translations[l][namespace][key]; ok && tr.text != "" {
+			return tr, l, true
+		}
+	}
+	return translation{}, lang, false
+}
+
+func (t *Translator) printer(lang string) *message.Printer {
+	if p, ok := t.printers[lang]; ok {
+		return p
+	}
+	return message.NewPrinter(pluralTag(lang))
+}
+
+// sprintf substitutes the args, escaping the text and args for the output. A single Args is formatted as an ICU
+// MessageFormat with the language printer, which localizes numbers, other args as printf verbs with fmt.
+func (t *Translator) sprintf(lang string, text string, args []any, out output) string {
+	if named, ok := args[0].(Args); ok && len(args) == 1 {
+		result, err := formatICU(lang, t.locale(lang), t.printer(lang), text, named, out)
+		if err != nil {
+			slog.Warn("error formatting message", slog.String("lang", lang), slog.String("error", err.Error()))
+			return out.escapeText(text)
+		}
+		return result
+	}
+	return fmt.Sprintf(out.escapeText(text), out.escapePrintfArgs(args)...)
+}
+
+func (t *Translator) translate(lang string, s Scope, key string, args []any, out output) string {
+	text := key
+	if tr, l, ok := t.lookup(lang, s, key); ok {
+		text, lang = tr.text, l
+	}
+	if len(args) == 0 {
+		if strings.ContainsAny(text, "'{") {
+			// ICU quoting still applies without args, such as It''s
+			return t.sprintf(lang, text, []any{Args{}}, out)
+		}
+		return out.escapeText(text)
+	}
+	return t.sprintf(lang, text, args, out)
+}
+
+func (t *Translator) translateN(lang string, s Scope, key string, count int, args []any, out output) string {
+	args = append([]any{count}, args...)
+	tr, lang, ok := t.lookup(lang, s, key)
+	if !ok {
+		return t.sprintf(lang, key, args, out)
+	}
+	text, ok := tr.plural[PluralCategory(lang, count)]
+	if !ok || text == "" {
+		text = tr.text
+	}
+	return t.sprintf(lang, text, args, out)
+}
@@ -0,0 +1,65 @@
+package ki18n
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranslator_independentInstances(t *testing.T) {
+	shop, err := NewTranslator(fstest.MapFS{
+		"fr-FR/index.yml": &fstest.MapFile{Data: []byte("Cart: Panier\n")},
+	}, "fr-FR")
+	require.NoError(t, err)
+	admin, err := NewTranslator(fstest.MapFS{
+		"es-ES/index.yml": &fstest.MapFile{Data: []byte("Cart: Carrito\n")},
+	}, "en-GB", Locale{Lang: "es-ES"})
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		translator *Translator
+		header     string
+		expected   string
+	}{
+		{shop, "es-ES", "Panier"},
+		{admin, "es-ES", "Carrito"},
+		{admin, "fr-FR", "Cart"},
+	} {
+		t.Run(tc.expected, func(t *testing.T) {
+			t.Parallel()
+			var result string
+			handler := tc.translator.LangMiddleware(AcceptLanguageStrategy)(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				result = render(t, Tr(r.Context(), "Cart"))
+			}))
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept-Language", tc.header)
+
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+			assert.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func TestTranslator_withTranslator(t *testing.T) {
+	translator, err := NewTranslator(nil, "fr-FR")
+	require.NoError(t, err)
+	now := time.Date(2026, time.May, 12, 12, 0, 0, 0, time.UTC)
+	translator.Now = func() time.Time { return now }
+	ctx := WithTranslator(context.Background(), translator)
+
+	assert.Equal(t, "12 mai 2026", FormatTime(ctx, now), "the default language of the translator is used")
+	assert.Equal(t, "il y a 2 jours", FormatRelative(ctx, now.AddDate(0, 0, -2)))
+	assert.Equal(t, "/fr/items", LocalizedPath(ctx, "/items"))
+}
+
+func TestNewTranslator_unregisteredDefaultLang(t *testing.T) {
+	_, err := NewTranslator(nil, "de-DE")
+
+	assert.ErrorIs(t, err, ErrUnregisteredLang)
+}
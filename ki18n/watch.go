@@ -0,0 +1,78 @@
+package ki18n
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"golang.org/x/exp/slog"
+)
+
+// reloadDelay groups the events of a save, as editors write files in several steps
+const reloadDelay = 100 * time.Millisecond
+
+// WatchLocales reloads the translations from the <lang>/<namespace>.yml files of dir on startup and when they change,
+// until ctx is done. It is meant for development: parse errors are logged, and the last good translations are kept.
+//
+//	go translator.WatchLocales(ctx, "locales")
+func (t *Translator) WatchLocales(ctx context.Context, dir string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("error creating locales watcher: %w", err)
+	}
+	defer watcher.Close()
+	if err := watcher.Add(dir); err != nil {
+		return fmt.Errorf("error watching %s: %w", dir, err)
+	}
+	for _, lang := range t.supported {
+		if err := watcher.Add(filepath.Join(dir, lang)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("error watching %s: %w", filepath.Join(dir, lang), err)
+		}
+	}
+
+	t.reloadDir(dir)
+	var reload <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			// Directories of languages created after startup are watched too
+			if event.Has(fsnotify.Create) && filepath.Dir(event.Name) == filepath.Clean(dir) && slices.Contains(t.supported, filepath.Base(event.Name)) {
+				if err := watcher.Add(event.Name); err != nil {
+					slog.Warn("error watching locales", slog.String("dir", event.Name), slog.String("error", err.Error()))
+				}
+			}
+			reload = time.After(reloadDelay)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			slog.Warn("error watching locales", slog.String("dir", dir), slog.String("error", err.Error()))
+		case <-reload:
+			reload = nil
+			t.reloadDir(dir)
+		}
+	}
+}
+
+func (t *Translator) reloadDir(dir string) {
+	if err := t.Reload(os.DirFS(dir)); err != nil {
+		slog.Error("error reloading locales, keeping the last good translations", slog.String("dir", dir), slog.String("error", err.Error()))
+		return
+	}
+	slog.Info("reloaded locales", slog.String("dir", dir))
+}
+
+// WatchLocales reloads the translations of the default translator set by Init from dir, see Translator.WatchLocales
+func WatchLocales(ctx context.Context, dir string) error {
+	return defaultTranslator.Load().WatchLocales(ctx, dir)
+}
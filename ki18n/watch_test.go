@@ -0,0 +1,61 @@
+package ki18n
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranslator_Reload(t *testing.T) {
+	translator, err := NewTranslator(fstest.MapFS{
+		"fr-FR/index.yml": &fstest.MapFile{Data: []byte("Cart: Panier\n")},
+	}, "fr-FR")
+	require.NoError(t, err)
+	ctx := WithTranslator(context.Background(), translator)
+
+	assert.NoError(t, translator.Reload(fstest.MapFS{
+		"fr-FR/index.yml": &fstest.MapFile{Data: []byte("Cart: Chariot\n")},
+	}))
+	assert.Equal(t, "Chariot", Str(ctx, "Cart"))
+
+	assert.Error(t, translator.Reload(fstest.MapFS{
+		"fr-FR/index.yml": &fstest.MapFile{Data: []byte("Cart: [Panier\n")},
+	}))
+	assert.Equal(t, "Chariot", Str(ctx, "Cart"), "the last good translations are kept")
+}
+
+func TestTranslator_WatchLocales(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "fr-FR"), 0o700))
+	file := filepath.Join(dir, "fr-FR", "index.yml")
+	require.NoError(t, os.WriteFile(file, []byte("Cart: Panier\n"), 0o600))
+	translator, err := NewTranslator(nil, "fr-FR")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- translator.WatchLocales(ctx, dir) }()
+	trCtx := WithTranslator(context.Background(), translator)
+
+	assert.Eventually(t, func() bool { return Str(trCtx, "Cart") == "Panier" }, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, os.WriteFile(file, []byte("Cart: Chariot\n"), 0o600))
+	assert.Eventually(t, func() bool { return Str(trCtx, "Cart") == "Chariot" }, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, os.WriteFile(file, []byte("Cart: [Panier\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "fr-FR", "billing.yml"), []byte("Pay: Payer\n"), 0o600))
+	time.Sleep(3 * reloadDelay)
+	assert.Equal(t, "Chariot", Str(trCtx, "Cart"), "parse errors keep the last good translations")
+	assert.Equal(t, "Pay", Namespace("billing").Str(trCtx, "Pay"))
+
+	require.NoError(t, os.WriteFile(file, []byte("Cart: Panier\n"), 0o600))
+	assert.Eventually(t, func() bool { return Namespace("billing").Str(trCtx, "Pay") == "Payer" }, time.Second, 10*time.Millisecond)
+
+	cancel()
+	assert.NoError(t, <-done)
+}